@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+)
+
+// Get how well the predicted pollen counts matched the measured ones from from to to, for a pollen type and
+// location. The prediction is compared to predicting the count measured the day before, in total and per month.
+func (context *httpContext) getAccuracyV2(responseWriter http.ResponseWriter, request *http.Request) {
+	query, err := context.parsePollenRange(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	location, err := context.Repo.GetLocation(query.Location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	// The day before from is the baseline of from
+	samples, err := context.Repo.GetPollenFromRange(query.From.AddDate(0, 0, -1), query.To, query.PollenType, query.Location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	accuracy := analysis.CalculateAccuracy(samples, query.From, query.To, refs.thresholds(query.PollenType))
+	writeObject(responseWriter, request, toAccuracyV2(accuracy, query, location, refs), nil)
+}
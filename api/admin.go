@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+	"github.com/gorilla/mux"
+)
+
+var errMissingCountryOrCity = errors.New("Both Country and City are required")
+
+var errIncompleteCoordinates = errors.New("Latitude and Longitude must be given together")
+
+var errMissingStationID = invalidParameter("StationID", errors.New("StationID is required"))
+
+var errAdminDisabled = &apiError{Status: http.StatusForbidden, Code: codeForbidden, Message: "Administration is disabled"}
+
+var errUnauthorized = &apiError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "Unauthorized"}
+
+// defaultTimeZone is used for locations created without a time zone
+const defaultTimeZone = "UTC"
+
+// requireAdmin only lets requests with the admin API key as bearer token through to next
+func (context *httpContext) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if context.Config.AdminAPIKey == "" {
+			writeError(responseWriter, request, errAdminDisabled)
+			return
+		}
+		authorization := request.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization || subtle.ConstantTimeCompare([]byte(token), []byte(context.Config.AdminAPIKey)) != 1 {
+			responseWriter.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(responseWriter, request, errUnauthorized)
+			return
+		}
+		next(responseWriter, request)
+	}
+}
+
+// Create a location from the JSON body. The location is given the next free id.
+func (context *httpContext) createLocation(responseWriter http.ResponseWriter, request *http.Request) {
+	location, err := readLocation(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	err = context.Repo.CreateLocation(location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(responseWriter).Encode(location)
+}
+
+// Update the country, city and time zone of a location from the JSON body.
+func (context *httpContext) updateLocation(responseWriter http.ResponseWriter, request *http.Request) {
+	locationID, err := strconv.Atoi(mux.Vars(request)["location"])
+	if err != nil {
+		writeError(responseWriter, request, errInvalidLocationID)
+		return
+	}
+	location, err := readLocation(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	location.Location = locationID
+
+	err = context.Repo.UpdateLocation(location)
+	writeObject(responseWriter, request, location, err)
+}
+
+// Delete a location. Locations with pollen data are only deleted when the query has cascade=true,
+// in which case all their data is deleted as well.
+func (context *httpContext) deleteLocation(responseWriter http.ResponseWriter, request *http.Request) {
+	locationID, err := strconv.Atoi(mux.Vars(request)["location"])
+	if err != nil {
+		writeError(responseWriter, request, errInvalidLocationID)
+		return
+	}
+	cascade := request.FormValue("cascade") == "true"
+
+	err = context.Repo.DeleteLocation(locationID, cascade)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	log.Printf("Deleted location %v (cascade: %v)", locationID, cascade)
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// readLocation reads a location from the JSON body of a request. TimeZone defaults to UTC.
+func readLocation(request *http.Request) (*dataaccess.Location, error) {
+	location := &dataaccess.Location{}
+	err := json.NewDecoder(request.Body).Decode(location)
+	if err != nil {
+		return nil, invalidParameter("", err)
+	}
+	return location, validateLocation(location)
+}
+
+// validateLocation checks a location to be saved. TimeZone defaults to UTC.
+func validateLocation(location *dataaccess.Location) error {
+	if location.Country == "" {
+		return invalidParameter("Country", errMissingCountryOrCity)
+	}
+	if location.City == "" {
+		return invalidParameter("City", errMissingCountryOrCity)
+	}
+	if location.TimeZone == "" {
+		location.TimeZone = defaultTimeZone
+	}
+	if err := dataaccess.ValidateTimeZone(location.TimeZone); err != nil {
+		return invalidParameter("TimeZone", err)
+	}
+	if (location.Latitude == nil) != (location.Longitude == nil) {
+		return invalidParameter("Latitude", errIncompleteCoordinates)
+	}
+	if location.Latitude != nil {
+		if err := dataaccess.ValidateCoordinates(*location.Latitude, *location.Longitude); err != nil {
+			return invalidParameter("Latitude", err)
+		}
+	}
+	return nil
+}
+
+// List all mappings from location and pollen type to astma-allergi.dk feed stations.
+func (context *httpContext) getFeedStations(responseWriter http.ResponseWriter, request *http.Request) {
+	stations, err := context.Repo.GetFeedStations()
+	writeObject(responseWriter, request, stations, err)
+}
+
+// Create or update the feed station of a location and pollen type from the JSON body. When the body
+// has no TypeID, the feed type id of the pollen type is used.
+func (context *httpContext) putFeedStation(responseWriter http.ResponseWriter, request *http.Request) {
+	location, pollenType, err := parseFeedStationKey(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	station := &dataaccess.FeedStation{}
+	err = json.NewDecoder(request.Body).Decode(station)
+	if err != nil {
+		writeError(responseWriter, request, invalidParameter("", err))
+		return
+	}
+	station.Location = location
+	station.PollenType = pollenType
+
+	err = context.saveFeedStation(station)
+	writeObject(responseWriter, request, station, err)
+}
+
+// saveFeedStation validates and completes a feed station, and stores it
+func (context *httpContext) saveFeedStation(station *dataaccess.FeedStation) error {
+	if station.StationID == 0 {
+		return errMissingStationID
+	}
+	if err := dataaccess.CompleteFeedStation(context.Repo, station); err != nil {
+		return err
+	}
+	return context.Repo.UpsertFeedStation(station)
+}
+
+// Delete the feed station of a location and pollen type. The collector skips it afterwards.
+func (context *httpContext) deleteFeedStation(responseWriter http.ResponseWriter, request *http.Request) {
+	location, pollenType, err := parseFeedStationKey(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	err = context.Repo.DeleteFeedStation(location, pollenType)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// parseFeedStationKey reads the location and pollen type from the path of a feed station request
+func parseFeedStationKey(request *http.Request) (int, dataaccess.PollenType, error) {
+	vars := mux.Vars(request)
+	location, err := strconv.Atoi(vars["location"])
+	if err != nil {
+		return 0, 0, errInvalidLocationID
+	}
+	pollenType, err := strconv.Atoi(vars["pollentype"])
+	if err != nil {
+		return 0, 0, errInvalidPollenType
+	}
+	return location, dataaccess.PollenType(pollenType), nil
+}
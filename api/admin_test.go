@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	handler := newTestServer(newTestRepository())
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"bearer token", "Bearer " + testAdminAPIKey, http.StatusNotFound},
+		{"no authorization", "", http.StatusUnauthorized},
+		{"key without scheme", testAdminAPIKey, http.StatusUnauthorized},
+		{"other scheme", "Basic " + testAdminAPIKey, http.StatusUnauthorized},
+		{"wrong token", "Bearer other-key", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, "/api/v2/location/7", nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("status %d, want %d", recorder.Code, test.status)
+			}
+		})
+	}
+}
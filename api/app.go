@@ -1,42 +1,114 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/admin"
 	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
 	"github.com/gorilla/mux"
 )
 
-// PollenSampleDto holds a pollencount for a given date
-type PollenSampleDto struct {
-	PollenCount          int       `json:"pollenCount"`
-	PredictedPollenCount int       `json:"predictedPollenCount"`
-	Date                 time.Time `json:"date"`
-}
-
-// PollenTypeDto has a pollen id and name
+// PollenTypeDto has a pollen id and names
 type PollenTypeDto struct {
 	PollenType dataaccess.PollenType `json:"pollenId"`
+	Code       string                `json:"code"`
 	PollenName string                `json:"name"`
+	NameDa     string                `json:"nameDa"`
+	LatinName  string                `json:"latinName"`
+}
+
+// LocationDistanceDto is a location and its distance in kilometers from the point searched for.
+// Its names follow the other v1 locations.
+type LocationDistanceDto struct {
+	Location   int
+	City       string
+	Country    string
+	TimeZone   string
+	Latitude   *float64
+	Longitude  *float64
+	Elevation  *float64
+	DistanceKm float64
+}
+
+// LocationDto is a location with the pollen types it has data for. Its names follow the other v1 locations.
+type LocationDto struct {
+	Location    int
+	City        string
+	Country     string
+	TimeZone    string
+	Latitude    *float64
+	Longitude   *float64
+	Elevation   *float64
+	PollenTypes []*PollenAvailabilityDto
 }
 
+// PollenAvailabilityDto has the first and last date with data for a pollen type at a location
+type PollenAvailabilityDto struct {
+	PollenType dataaccess.PollenType `json:"pollenId"`
+	Code       string                `json:"code"`
+	FirstDate  time.Time             `json:"firstDate"`
+	LastDate   time.Time             `json:"lastDate"`
+}
+
+// LocationSearchDto is a page of locations found by a search, best match first
+type LocationSearchDto struct {
+	Locations []*dataaccess.Location `json:"locations"`
+	Total     int                    `json:"total"`
+	Offset    int                    `json:"offset"`
+	Limit     int                    `json:"limit"`
+}
+
+// defaultNearestLimit is the number of locations returned by the nearest lookup without a limit
+const defaultNearestLimit = 5
+
+var errInvalidLimit = invalidParameter("limit", errors.New("Limit must be a positive number"))
+var errInvalidRadius = invalidParameter("radius", errors.New("Radius must be a number, not negative"))
+var errInvalidOffset = invalidParameter("offset", errors.New("Offset must not be negative"))
+var errInvalidSearchLimit = invalidParameter("limit", fmt.Errorf("Limit must be between 1 and %v", dataaccess.MaxSearchLimit))
+var errMissingSearchTerm = invalidParameter("country", errors.New("Search for country, city or both"))
+var errInvalidLatitude = invalidParameter("lat", errors.New("Latitude must be a number between -90 and 90"))
+var errInvalidLongitude = invalidParameter("lon", errors.New("Longitude must be a number between -180 and 180"))
+var errInvalidLocationID = invalidParameter("location", errors.New("location must be an integer"))
+var errInvalidPollenType = invalidParameter("pollentype", errors.New("pollentype must be an integer"))
+
 type httpContext struct {
-	Repo *dataaccess.PollenRepository
+	Repo   dataaccess.PollenStore
+	Config *APIConfig
+	Clock  dataaccess.Clock
 }
 
 func main() {
+	flag.Parse()
 	// Change directory to the same as executable
 	executable, _ := os.Executable()
 	exPath := filepath.Dir(executable)
 	os.Chdir(exPath)
 
+	// Administration commands, e.g. "migrate up", run instead of the API
+	if flag.NArg() > 0 {
+		repo, err := dataaccess.GetConnection()
+		if err != nil {
+			panic("Cannot connect to db")
+		}
+		defer repo.Close()
+		if err := admin.Run(repo, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	logfile, err := os.OpenFile("pollen-api.log", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		log.Fatal(err)
@@ -52,37 +124,91 @@ func main() {
 	}
 	repo.InitDb()
 	context := &httpContext{
-		Repo: repo,
+		Repo:   repo,
+		Config: getConfig(),
+		Clock:  dataaccess.SystemClock,
+	}
+
+	router := newRouter(context)
+
+	// openapi.json must describe every route, so report any differences
+	problems, err := checkOpenAPISpec(router)
+	if err != nil {
+		log.Fatal(err)
 	}
+	for _, problem := range problems {
+		log.Printf("Warning: %s", problem)
+	}
+
+	http.ListenAndServe(":8001", requestIDMiddleware(trailingSlashMiddleware(router)))
+}
 
+// newRouter registers every endpoint of the API
+func newRouter(context *httpContext) *mux.Router {
 	router := mux.NewRouter()
-	apiRouter := router.PathPrefix("/api").Subrouter()
+	router.HandleFunc("/api/openapi.json", getOpenAPISpec).
+		Methods(http.MethodGet)
+	router.HandleFunc("/api/docs", getDocs).
+		Methods(http.MethodGet)
 
-	apiRouter.HandleFunc("/pollentype", context.getPollenTypes)
+	registerV2(router.PathPrefix("/api/v2").Subrouter(), context)
 
-	apiRouter.HandleFunc("/location/{location}", context.getLocation)
+	// v1 is frozen, its responses must not change. New features are only added to v2.
+	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(deprecationMiddleware)
+
+	apiRouter.HandleFunc("/pollentype", context.getPollenTypes).
+		Methods(http.MethodGet)
+
+	// Administration, requires the admin API key
+	apiRouter.HandleFunc("/location", context.requireAdmin(context.createLocation)).
+		Methods(http.MethodPost)
+	apiRouter.HandleFunc("/location/{location}", context.requireAdmin(context.updateLocation)).
+		Methods(http.MethodPut)
+	apiRouter.HandleFunc("/location/{location}", context.requireAdmin(context.deleteLocation)).
+		Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/feedstation", context.requireAdmin(context.getFeedStations)).
+		Methods(http.MethodGet)
+	apiRouter.HandleFunc("/feedstation/{location}/{pollentype}", context.requireAdmin(context.putFeedStation)).
+		Methods(http.MethodPut)
+	apiRouter.HandleFunc("/feedstation/{location}/{pollentype}", context.requireAdmin(context.deleteFeedStation)).
+		Methods(http.MethodDelete)
+
+	apiRouter.HandleFunc("/locations", context.getLocations).
+		Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/location/nearest", context.getNearestLocations).
+		Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/location/{location}", context.getLocation).
+		Methods(http.MethodGet)
 
 	apiRouter.HandleFunc("/location", context.searchLocation).
-		Queries(
-			"country", "{country}",
-			"city", "{city}")
+		Methods(http.MethodGet)
 
 	// For temporary backwards compatibility. Is deprecated.
-	apiRouter.HandleFunc("/pollen/{date}", context.getPollen)
+	apiRouter.HandleFunc("/pollen/{date}", context.getPollen).
+		Methods(http.MethodGet)
 
 	apiRouter.HandleFunc("/pollen/{date}", context.getPollen).
 		Queries(
 			"pollentype", "{pollentype}",
-			"location", "{location}")
+			"location", "{location}").
+		Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/forecast/{date}/history", context.getForecastHistory).
+		Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/forecast/{date}", context.getForecast).
+		Methods(http.MethodGet)
 
 	apiRouter.HandleFunc("/pollen", context.getPollenRange).
-		Queries(
-			"from", "{from}",
-			"to", "{to}",
-			"pollentype", "{pollentype}",
-			"location", "{location}")
+		Methods(http.MethodGet)
+
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 
-	http.ListenAndServe(":8001", trailingSlashMiddleware(router))
+	return router
 }
 
 func trailingSlashMiddleware(next http.Handler) http.Handler {
@@ -92,30 +218,35 @@ func trailingSlashMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Write an object to the output stream as a JSON blob. Handles the most common error codes as well.
-func writeObject(responseWriter http.ResponseWriter, output *json.Encoder, object interface{}, err error) {
+// Write an object to the output stream as a JSON blob. Errors are written with writeError, and a nil
+// object is not found.
+func writeObject(responseWriter http.ResponseWriter, request *http.Request, object interface{}, err error) {
 	if err != nil {
-		responseWriter.WriteHeader(http.StatusInternalServerError)
-		log.Println(err)
-		output.Encode(err)
+		writeError(responseWriter, request, err)
 		return
 	}
-	if object == nil {
-		responseWriter.WriteHeader(http.StatusNotFound)
-		output.Encode("Object not found")
+	if object == nil || reflect.ValueOf(object).Kind() == reflect.Ptr && reflect.ValueOf(object).IsNil() {
+		writeError(responseWriter, request, notFound("Object not found"))
 		return
 	}
-	output.Encode(object)
+	responseWriter.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(responseWriter).Encode(object)
+}
+
+// parseIntParameter reads a query parameter that must be an integer
+func parseIntParameter(request *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(request.FormValue(name))
+	if err != nil {
+		return 0, invalidParameter(name, fmt.Errorf("%s must be an integer", name))
+	}
+	return value, nil
 }
 
 // Get a list of the current pollen types handled by the API.
 func (context *httpContext) getPollenTypes(responseWriter http.ResponseWriter, request *http.Request) {
-	output := json.NewEncoder(responseWriter)
-
 	types, err := context.Repo.GetPollenTypes()
 	if err != nil {
-		responseWriter.WriteHeader(http.StatusInternalServerError)
-		output.Encode(err)
+		writeError(responseWriter, request, err)
 		return
 	}
 
@@ -123,69 +254,233 @@ func (context *httpContext) getPollenTypes(responseWriter http.ResponseWriter, r
 
 	for i, pollenType := range types {
 		result[i] = PollenTypeDto{
-			PollenType: pollenType,
-			PollenName: pollenType.String(),
+			PollenType: pollenType.PollenType,
+			Code:       pollenType.Code,
+			PollenName: pollenType.NameEn,
+			NameDa:     pollenType.NameDa,
+			LatinName:  pollenType.LatinName,
 		}
 	}
 
-	output.Encode(result)
+	writeObject(responseWriter, request, result, nil)
 }
 
 // Get a location by an id.
 func (context *httpContext) getLocation(responseWriter http.ResponseWriter, request *http.Request) {
-	output := json.NewEncoder(responseWriter)
 	vars := mux.Vars(request)
 
 	locationID, err := strconv.Atoi(vars["location"])
 	if err != nil {
-		responseWriter.WriteHeader(http.StatusBadRequest)
-		output.Encode(err)
+		writeError(responseWriter, request, errInvalidLocationID)
 		return
 	}
 
 	location, err := context.Repo.GetLocation(locationID)
 
-	writeObject(responseWriter, output, location, err)
+	writeObject(responseWriter, request, location, err)
+}
+
+// Get every location, each with the pollen types it has data for and the first and last date of the data.
+func (context *httpContext) getLocations(responseWriter http.ResponseWriter, request *http.Request) {
+	locations, err := context.Repo.GetAllLocations()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	availabilities, err := context.Repo.GetPollenAvailability()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	pollenTypes, err := context.Repo.GetPollenTypes()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	codes := make(map[dataaccess.PollenType]string)
+	for _, pollenType := range pollenTypes {
+		codes[pollenType.PollenType] = pollenType.Code
+	}
+
+	byLocation := make(map[int][]*PollenAvailabilityDto)
+	for _, availability := range availabilities {
+		byLocation[availability.Location] = append(byLocation[availability.Location], &PollenAvailabilityDto{
+			PollenType: availability.PollenType,
+			Code:       codes[availability.PollenType],
+			FirstDate:  availability.FirstDate,
+			LastDate:   availability.LastDate,
+		})
+	}
+
+	result := make([]*LocationDto, len(locations))
+	for i, location := range locations {
+		result[i] = &LocationDto{
+			Location:    location.Location,
+			City:        location.City,
+			Country:     location.Country,
+			TimeZone:    location.TimeZone,
+			Latitude:    location.Latitude,
+			Longitude:   location.Longitude,
+			Elevation:   location.Elevation,
+			PollenTypes: byLocation[location.Location],
+		}
+		if result[i].PollenTypes == nil {
+			result[i].PollenTypes = []*PollenAvailabilityDto{}
+		}
+	}
+	writeObject(responseWriter, request, result, nil)
 }
 
-// Find a location by either city of country. Useful to get the location id for use with getPollen.
+// Search locations by country and/or city. Matching ignores case and accents, and exact matches are ranked
+// before prefix matches, which are ranked before substring matches. Useful to get the location id for use
+// with getPollen. The results are paged with offset and limit.
 func (context *httpContext) searchLocation(responseWriter http.ResponseWriter, request *http.Request) {
-	output := json.NewEncoder(responseWriter)
+	query, err := parseLocationQuery(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	result, err := context.Repo.SearchLocation(query)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, &LocationSearchDto{
+		Locations: result.Locations,
+		Total:     result.Total,
+		Offset:    query.Offset,
+		Limit:     query.Limit,
+	}, nil)
+}
+
+// parseLocationQuery reads the query parameters of a location search
+func parseLocationQuery(request *http.Request) (*dataaccess.LocationQuery, error) {
+	query := &dataaccess.LocationQuery{
+		Country: request.FormValue("country"),
+		City:    request.FormValue("city"),
+	}
+	if strings.TrimSpace(query.Country) == "" && strings.TrimSpace(query.City) == "" {
+		return nil, errMissingSearchTerm
+	}
+
+	var err error
+	query.Radius, err = parseRadiusFilter(request)
+	if err != nil {
+		return nil, err
+	}
+	query.Offset, query.Limit, err = parsePage(request)
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// parsePage reads the optional query parameters offset and limit of a paged request
+func parsePage(request *http.Request) (int, int, error) {
+	offset, limit := 0, dataaccess.DefaultSearchLimit
+	var err error
+	if request.FormValue("offset") != "" {
+		offset, err = strconv.Atoi(request.FormValue("offset"))
+		if err != nil || offset < 0 {
+			return 0, 0, errInvalidOffset
+		}
+	}
+	if request.FormValue("limit") != "" {
+		limit, err = strconv.Atoi(request.FormValue("limit"))
+		if err != nil || limit < 1 || limit > dataaccess.MaxSearchLimit {
+			return 0, 0, errInvalidSearchLimit
+		}
+	}
+	return offset, limit, nil
+}
 
-	country := request.FormValue("country")
-	city := request.FormValue("city")
-	if country == "" && city == "" {
-		responseWriter.WriteHeader(http.StatusBadRequest)
+// Get the locations nearest to a point given by lat and lon, nearest first, with their distance in kilometers.
+// limit is the maximum number of locations returned and defaults to 5.
+func (context *httpContext) getNearestLocations(responseWriter http.ResponseWriter, request *http.Request) {
+	nearestLocations, err := context.findNearestLocations(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
 		return
 	}
 
-	location, err := context.Repo.SearchLocation(country, city)
+	results := []*LocationDistanceDto{}
+	for _, nearest := range nearestLocations {
+		results = append(results, &LocationDistanceDto{
+			Location:   nearest.Location.Location,
+			City:       nearest.Location.City,
+			Country:    nearest.Location.Country,
+			TimeZone:   nearest.Location.TimeZone,
+			Latitude:   nearest.Location.Latitude,
+			Longitude:  nearest.Location.Longitude,
+			Elevation:  nearest.Location.Elevation,
+			DistanceKm: nearest.DistanceKm,
+		})
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// findNearestLocations finds the locations nearest to the point of the query parameters lat and lon.
+// The query parameter limit is the maximum number of locations, and defaults to 5.
+func (context *httpContext) findNearestLocations(request *http.Request) ([]*dataaccess.LocationDistance, error) {
+	latitude, longitude, err := parseCoordinates(request)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := defaultNearestLimit
+	if request.FormValue("limit") != "" {
+		limit, err = strconv.Atoi(request.FormValue("limit"))
+		if err != nil || limit < 1 {
+			return nil, errInvalidLimit
+		}
+	}
 
-	writeObject(responseWriter, output, location, err)
+	locations, err := context.Repo.GetAllLocations()
+	if err != nil {
+		return nil, err
+	}
+	return dataaccess.NearestLocations(locations, latitude, longitude, limit), nil
+}
+
+// parseCoordinates reads and validates the query parameters lat and lon
+func parseCoordinates(request *http.Request) (float64, float64, error) {
+	latitude, err := strconv.ParseFloat(request.FormValue("lat"), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, errInvalidLatitude
+	}
+	longitude, err := strconv.ParseFloat(request.FormValue("lon"), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, errInvalidLongitude
+	}
+	return latitude, longitude, nil
+}
+
+// parseRadiusFilter reads the optional query parameters lat, lon and radius (in kilometers). All three must be
+// given to filter by radius. Returns nil if none are given.
+func parseRadiusFilter(request *http.Request) (*dataaccess.RadiusFilter, error) {
+	if request.FormValue("lat") == "" && request.FormValue("lon") == "" && request.FormValue("radius") == "" {
+		return nil, nil
+	}
+	latitude, longitude, err := parseCoordinates(request)
+	if err != nil {
+		return nil, err
+	}
+	radius, err := strconv.ParseFloat(request.FormValue("radius"), 64)
+	if err != nil || radius < 0 {
+		return nil, errInvalidRadius
+	}
+	return &dataaccess.RadiusFilter{Latitude: latitude, Longitude: longitude, RadiusKm: radius}, nil
 }
 
 // Get the pollen count as well as the predicted pollen count for a given date, pollen type and location.
-// If the date is the string "tomorrow", the correct date for tomorrow will be used.
+// If the date is the string "yesterday", "today" or "tomorrow", the date is resolved in the calendar of the location.
 // Pollen type and location can be omitted for now, and will simply use grass pollen for copenhagen
 // for backwards compatibility. It is deprecated and will be removed once tomorrowspollen.today is
 // updated to use the new parameters.
 func (context *httpContext) getPollen(responseWriter http.ResponseWriter, request *http.Request) {
-	output := json.NewEncoder(responseWriter)
 	vars := mux.Vars(request)
 
-	var date time.Time
-	if vars["date"] == "tomorrow" {
-		date = time.Now().AddDate(0, 0, 1)
-	} else {
-		var err error
-		date, err = time.Parse(time.RFC3339, vars["date"])
-		if err != nil {
-			responseWriter.WriteHeader(http.StatusBadRequest)
-			output.Encode(err)
-			return
-		}
-	}
-
 	// Parse pollen type
 	pollenType, err := strconv.Atoi(request.FormValue("pollentype"))
 	if err != nil {
@@ -202,48 +497,217 @@ func (context *httpContext) getPollen(responseWriter http.ResponseWriter, reques
 		location = 0
 	}
 
+	date, err := context.parseDate("date", vars["date"], location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
 	pollenData, err := context.Repo.GetPollen(
-		dataaccess.TimestampToDate(date),
+		date,
 		dataaccess.PollenType(pollenType),
 		location)
-	writeObject(responseWriter, output, pollenData, err)
+	writeObject(responseWriter, request, pollenData, err)
 }
 
-func (context *httpContext) getPollenRange(responseWriter http.ResponseWriter, request *http.Request) {
-	output := json.NewEncoder(responseWriter)
+// Get the forecast for a given date, pollen type and location. Without the query parameter issued, the
+// latest forecast is returned. With issued, the forecast as it was published on that day is returned.
+func (context *httpContext) getForecast(responseWriter http.ResponseWriter, request *http.Request) {
+	forecast, err := context.findForecast(request)
+	writeObject(responseWriter, request, forecast, err)
+}
 
-	from, err := time.Parse(time.RFC3339, request.FormValue("from"))
+// findForecast finds the forecast of a request to getForecast
+func (context *httpContext) findForecast(request *http.Request) (*dataaccess.PollenForecast, error) {
+	date, pollenType, location, err := context.parseForecastRequest(request)
 	if err != nil {
-		output.Encode(err)
-		return
+		return nil, err
+	}
+	if request.FormValue("issued") == "" {
+		return context.Repo.GetLatestPollenForecast(date, pollenType, location)
+	}
+	issued, err := context.parseIssued(request, location)
+	if err != nil {
+		return nil, err
+	}
+	return context.Repo.GetPollenForecastIssuedOn(date, pollenType, location, issued)
+}
+
+// parseIssued reads the query parameter issued as a date in the calendar of the location. Dates are taken as
+// they are, and the date of a timestamp is the one it falls on in the calendar of the location.
+func (context *httpContext) parseIssued(request *http.Request, locationID int) (time.Time, error) {
+	location, err := context.Repo.GetLocation(locationID)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return parseDateParameter(request, "issued", location)
+}
 
-	to, err := time.Parse(time.RFC3339, request.FormValue("to"))
+// Get every forecast issued for a given date, pollen type and location, oldest first.
+func (context *httpContext) getForecastHistory(responseWriter http.ResponseWriter, request *http.Request) {
+	date, pollenType, location, err := context.parseForecastRequest(request)
 	if err != nil {
-		output.Encode(err)
+		writeError(responseWriter, request, err)
 		return
 	}
 
+	forecasts, err := context.Repo.GetPollenForecasts(date, pollenType, location)
+	writeObject(responseWriter, request, forecasts, err)
+}
+
+// parseForecastRequest reads the date, pollen type and location of a forecast request
+func (context *httpContext) parseForecastRequest(request *http.Request) (time.Time, dataaccess.PollenType, int, error) {
+	pollenType, err := parseIntParameter(request, "pollentype")
+	if err != nil {
+		return time.Time{}, 0, 0, err
+	}
+	location, err := parseIntParameter(request, "location")
+	if err != nil {
+		return time.Time{}, 0, 0, err
+	}
+	date, err := context.parseDate("date", mux.Vars(request)["date"], location)
+	if err != nil {
+		return date, 0, 0, err
+	}
+	return date, dataaccess.PollenType(pollenType), location, nil
+}
+
+// parseDate parses an ISO date, an RFC3339 timestamp or one of the strings "yesterday", "today" and "tomorrow"
+// to a date. Timestamps stand for the date they fall on in the calendar of the location, and the strings are
+// resolved there at the time of the clock of the context. Returns sql.ErrNoRows if they need the location and
+// it doesn't exist. field names the parameter in errors.
+func (context *httpContext) parseDate(field string, value string, locationID int) (time.Time, error) {
+	if date, err := time.Parse(isoDate, value); err == nil {
+		return date, nil
+	}
+	// Invalid values are reported before unknown locations
+	if _, err := context.parseDateIn(field, value, nil); err != nil {
+		return time.Time{}, err
+	}
+	location, err := context.Repo.GetLocation(locationID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return context.parseDateIn(field, value, location)
+}
+
+// parseDateIn parses a date like parseDate in the calendar of location, or of UTC if location is nil
+func (context *httpContext) parseDateIn(field string, value string, location *dataaccess.Location) (time.Time, error) {
+	if location == nil {
+		location = &dataaccess.Location{TimeZone: "UTC"}
+	}
+	if date, ok := location.RelativeDate(context.Clock, value); ok {
+		return date, nil
+	}
+	date, err := dateIn(value, location)
+	if err != nil {
+		return date, invalidParameter(field, fmt.Errorf(
+			"%s must be an RFC3339 timestamp, a date like 2020-05-01, yesterday, today or tomorrow", field))
+	}
+	return date, nil
+}
+
+// parseDateParameter reads the query parameter name as an ISO date or an RFC3339 timestamp in the calendar
+// of location, see dateIn
+func parseDateParameter(request *http.Request, name string, location *dataaccess.Location) (time.Time, error) {
+	date, err := dateIn(request.FormValue(name), location)
+	if err != nil {
+		return date, invalidParameter(name, fmt.Errorf("%s must be an RFC3339 timestamp or a date like 2020-05-01", name))
+	}
+	return date, nil
+}
+
+// dateIn parses an ISO date, which is taken as it is, or an RFC3339 timestamp, which stands for the date it
+// falls on in the calendar of location
+func dateIn(value string, location *dataaccess.Location) (time.Time, error) {
+	if date, err := time.Parse(isoDate, value); err == nil {
+		return date, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return timestamp, err
+	}
+	return location.Date(timestamp), nil
+}
+
+func (context *httpContext) getPollenRange(responseWriter http.ResponseWriter, request *http.Request) {
+	pollenData, err := context.findPollenRange(request)
+	writeObject(responseWriter, request, pollenData, err)
+}
+
+// pollenRangeQuery is a range of dates of pollen data of a pollen type and location
+type pollenRangeQuery struct {
+	From       time.Time
+	To         time.Time
+	PollenType dataaccess.PollenType
+	Location   int
+}
+
+// findPollenRange finds the pollen data of the query parameters from, to, pollentype and location.
+// It serves v1, so unlike parsePollenRange it only takes timestamps and dates.
+func (context *httpContext) findPollenRange(request *http.Request) ([]*dataaccess.PollenSample, error) {
 	// Parse pollen type
-	pollenType, err := strconv.Atoi(request.FormValue("pollentype"))
+	pollenType, err := parseIntParameter(request, "pollentype")
 	if err != nil {
-		responseWriter.WriteHeader(http.StatusBadRequest)
-		output.Encode(err)
-		return
+		return nil, err
 	}
 
 	// Parse location
-	location, err := strconv.Atoi(request.FormValue("location"))
+	location, err := parseIntParameter(request, "location")
 	if err != nil {
-		responseWriter.WriteHeader(http.StatusBadRequest)
-		output.Encode(err)
-		return
+		return nil, err
+	}
+	// v1 answers unknown locations with no data, so their timestamps are taken in UTC
+	calendar, err := context.Repo.GetLocation(location)
+	if err == sql.ErrNoRows {
+		calendar, err = &dataaccess.Location{Location: location, TimeZone: "UTC"}, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	pollenData, err := context.Repo.GetPollenFromRange(
-		dataaccess.TimestampToDate(from),
-		dataaccess.TimestampToDate(to),
-		dataaccess.PollenType(pollenType),
-		location)
-	writeObject(responseWriter, output, pollenData, err)
+	from, err := parseDateParameter(request, "from", calendar)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseDateParameter(request, "to", calendar)
+	if err != nil {
+		return nil, err
+	}
+
+	return context.Repo.GetPollenFromRange(from, to, dataaccess.PollenType(pollenType), location)
+}
+
+// parsePollenRange reads the query parameters from, to, pollentype and location. from and to are parsed
+// like the date of getPollen, so e.g. from=yesterday&to=tomorrow follows the calendar.
+func (context *httpContext) parsePollenRange(request *http.Request) (*pollenRangeQuery, error) {
+	// Parse pollen type
+	pollenType, err := parseIntParameter(request, "pollentype")
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse location
+	location, err := parseIntParameter(request, "location")
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := context.parseDate("from", request.FormValue("from"), location)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := context.parseDate("to", request.FormValue("to"), location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pollenRangeQuery{
+		From:       from,
+		To:         to,
+		PollenType: dataaccess.PollenType(pollenType),
+		Location:   location,
+	}, nil
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// v1 is frozen, so its range only takes the timestamps and dates it always did
+
+func TestV1RangeTakesNoRelativeDates(t *testing.T) {
+	handler := newTestServer(newTestRepository())
+
+	recorder, result := serveError(t, handler, http.MethodGet, "/api/pollen?from=yesterday&to=today&pollentype=0&location=0", "")
+	if recorder.Code != http.StatusBadRequest || result.Field != "from" {
+		t.Errorf("status %d on field %q, want 400 on field from", recorder.Code, result.Field)
+	}
+}
+
+// Copenhagen turns to daylight saving time at 2020-03-29T01:00:00Z, so today is a day later there than in UTC
+// from 23:00 UTC before it and from 22:00 UTC after it
+func TestTodayFollowsTheCalendarOfTheLocation(t *testing.T) {
+	repo := newTestRepository()
+	for day := 28; day <= 30; day++ {
+		count := day
+		repo.UpsertPollenCount(&dataaccess.PollenSample{
+			Date: time.Date(2020, time.March, day, 0, 0, 0, 0, time.UTC), Location: dataaccess.Location{Location: 0}, PollenCount: &count,
+		})
+	}
+
+	tests := []struct {
+		now  time.Time
+		date string
+	}{
+		{time.Date(2020, time.March, 28, 22, 59, 0, 0, time.UTC), "2020-03-28"},
+		{time.Date(2020, time.March, 28, 23, 0, 0, 0, time.UTC), "2020-03-29"},
+		{time.Date(2020, time.March, 29, 21, 59, 0, 0, time.UTC), "2020-03-29"},
+		{time.Date(2020, time.March, 29, 22, 0, 0, 0, time.UTC), "2020-03-30"},
+	}
+	for _, test := range tests {
+		now := test.now
+		context := &httpContext{Repo: repo, Config: &APIConfig{}, Clock: dataaccess.ClockFunc(func() time.Time { return now })}
+		recorder := httptest.NewRecorder()
+		newRouter(context).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/pollen/today?pollentype=0&location=0", nil))
+		var sample PollenSampleV2Dto
+		if err := json.NewDecoder(recorder.Body).Decode(&sample); err != nil {
+			t.Fatal(err)
+		}
+		if sample.Date != test.date {
+			t.Errorf("at %s today is %s, want %s", now.Format(time.RFC3339), sample.Date, test.date)
+		}
+	}
+}
+
+// 2020-05-01T23:30:00Z is 01:30 on May 2 in Copenhagen, on every endpoint taking a timestamp as a date
+func TestTimestampsFollowTheCalendarOfTheLocation(t *testing.T) {
+	repo := newTestRepository()
+	for day := 1; day <= 2; day++ {
+		count := day
+		repo.UpsertPollenCount(&dataaccess.PollenSample{
+			Date: time.Date(2020, time.May, day, 0, 0, 0, 0, time.UTC), Location: dataaccess.Location{Location: 0}, PollenCount: &count,
+		})
+	}
+	handler := newTestServer(repo)
+	const timestamp = "2020-05-01T23:30:00Z"
+
+	urls := []string{
+		"/api/v2/pollen/" + timestamp + "?pollentype=0&location=0",
+		"/api/v2/pollen?from=" + timestamp + "&to=" + timestamp + "&pollentype=0&location=0",
+		"/api/pollen?from=" + timestamp + "&to=" + timestamp + "&pollentype=0&location=0",
+		"/api/pollen/" + timestamp + "?pollentype=0&location=0",
+	}
+	for _, url := range urls {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		var body interface{}
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		samples, ok := body.([]interface{})
+		if !ok {
+			samples = []interface{}{body}
+		}
+		if len(samples) != 1 {
+			t.Errorf("%s: got %d samples, want 1", url, len(samples))
+			continue
+		}
+		sample := samples[0].(map[string]interface{})
+		count, ok := sample["pollenCount"]
+		if !ok {
+			count = sample["PollenCount"]
+		}
+		if count != 2.0 {
+			t.Errorf("%s: got %v, want the count 2 of May 2", url, sample)
+		}
+	}
+}
+
+// The v1 locations with availability or a distance have the names of a plain v1 location
+func TestV1LocationsHaveOneNamingConvention(t *testing.T) {
+	latitude, longitude := 55.6761, 12.5683
+	repo := dataaccess.NewMemoryRepository(dataaccess.Location{
+		Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen", Latitude: &latitude, Longitude: &longitude,
+	})
+	handler := newTestServer(repo)
+
+	tests := []struct {
+		url  string
+		keys []string
+	}{
+		{"/api/location/0", nil},
+		{"/api/locations", []string{"PollenTypes"}},
+		{"/api/location/nearest?lat=56&lon=10", []string{"DistanceKm"}},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
+		var location map[string]interface{}
+		var locations []map[string]interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &locations); err == nil && len(locations) == 1 {
+			location = locations[0]
+		} else if err := json.Unmarshal(recorder.Body.Bytes(), &location); err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+		keys := append([]string{"Location", "City", "Country", "TimeZone", "Latitude", "Longitude", "Elevation"}, test.keys...)
+		if len(location) != len(keys) {
+			t.Errorf("%s has %d names, want %v", test.url, len(location), keys)
+		}
+		for _, key := range keys {
+			if _, ok := location[key]; !ok {
+				t.Errorf("%s has no %s", test.url, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// maxClimatologyWindow is the largest number of days before and after a day of year pooled with it
+const maxClimatologyWindow = 30
+
+var errInvalidFromYear = invalidParameter("fromYear", errors.New("fromYear must be a year no later than toYear"))
+
+var errInvalidToYear = invalidParameter("toYear", errors.New("toYear must be a year"))
+
+var errInvalidWindow = invalidParameter("window", errors.New("window must be an integer from 0 to 30"))
+
+// climatologyPeriod is the reference period of a climatology and the days pooled with each day of year
+type climatologyPeriod struct {
+	FromYear int
+	ToYear   int
+	Window   int
+}
+
+// Get the normal pollen counts of every day of year for a pollen type and location: the mean, median and
+// percentiles of the measured counts of the reference period.
+func (context *httpContext) getClimatologyV2(responseWriter http.ResponseWriter, request *http.Request) {
+	pollenType, err := parseIntParameter(request, "pollentype")
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	locationID, err := parseIntParameter(request, "location")
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	location, err := context.Repo.GetLocation(locationID)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	period, err := context.parseClimatologyPeriod(request, location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	climatology, err := context.findClimatology(period, dataaccess.PollenType(pollenType), locationID)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, toClimatologyV2(climatology, period,
+		refs.ref(dataaccess.PollenType(pollenType)), location), nil)
+}
+
+// parseClimatologyPeriod reads the query parameters fromYear, toYear and window. toYear defaults to the last
+// complete year in the calendar of the location, fromYear to 10 years up to toYear and window to 3 days.
+func (context *httpContext) parseClimatologyPeriod(request *http.Request, location *dataaccess.Location) (*climatologyPeriod, error) {
+	period := &climatologyPeriod{
+		ToYear: location.Today(context.Clock).Year() - 1,
+		Window: analysis.DefaultClimatologyWindow,
+	}
+	var err error
+	if value := request.FormValue("toYear"); value != "" {
+		if period.ToYear, err = strconv.Atoi(value); err != nil {
+			return nil, errInvalidToYear
+		}
+	}
+	period.FromYear = period.ToYear - 9
+	if value := request.FormValue("fromYear"); value != "" {
+		if period.FromYear, err = strconv.Atoi(value); err != nil || period.FromYear > period.ToYear {
+			return nil, errInvalidFromYear
+		}
+	}
+	if value := request.FormValue("window"); value != "" {
+		period.Window, err = strconv.Atoi(value)
+		if err != nil || period.Window < 0 || period.Window > maxClimatologyWindow {
+			return nil, errInvalidWindow
+		}
+	}
+	return period, nil
+}
+
+// findClimatology calculates the climatology of a pollen type and location over a reference period
+func (context *httpContext) findClimatology(period *climatologyPeriod, pollenType dataaccess.PollenType, location int) (*analysis.Climatology, error) {
+	from := time.Date(period.FromYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(period.ToYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+	samples, err := context.Repo.GetPollenFromRange(from, to, pollenType, location)
+	if err != nil {
+		return nil, err
+	}
+	return analysis.CalculateClimatology(samples, period.Window), nil
+}
+
+// climatologyKey identifies the climatology of a location and pollen type
+type climatologyKey struct {
+	location   int
+	pollenType dataaccess.PollenType
+}
+
+// addPercentilesOfNormal sets the percentileOfNormal of the results of samples with a measured count, when the
+// query has normal=true. Filled counts weren't measured, so they get none. The climatologies are those of the
+// locations and pollen types of query, see findClimatologies.
+func (context *httpContext) addPercentilesOfNormal(request *http.Request, query *dataaccess.PollenQuery,
+	samples []*dataaccess.PollenSample, results []*PollenSampleV2Dto) error {
+	if request.FormValue("normal") != "true" {
+		return nil
+	}
+	climatologies, err := context.findClimatologies(request, query)
+	if err != nil {
+		return err
+	}
+	for i, sample := range samples {
+		climatology := climatologies[climatologyKey{sample.Location.Location, sample.PollenType}]
+		filled := results[i].Status != nil && *results[i].Status == string(analysis.DayFilled)
+		if climatology != nil && sample.PollenCount != nil && !filled {
+			results[i].PercentileOfNormal = climatology.PercentileOfNormal(sample.Date, float64(*sample.PollenCount))
+		}
+	}
+	return nil
+}
+
+// findClimatologies calculates the climatologies of the locations and pollen types of query, whose dates are
+// ignored. The reference period of each location is read like the one of getClimatologyV2, and the counts of
+// all of them are found with a single query. Locations and pollen types without counts have no climatology.
+func (context *httpContext) findClimatologies(request *http.Request, query *dataaccess.PollenQuery) (map[climatologyKey]*analysis.Climatology, error) {
+	locations, err := context.Repo.GetAllLocations()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[int]bool)
+	for _, location := range query.Locations {
+		wanted[location] = true
+	}
+	periods := make(map[int]*climatologyPeriod)
+	var from, to time.Time
+	for _, location := range locations {
+		if len(wanted) > 0 && !wanted[location.Location] {
+			continue
+		}
+		period, err := context.parseClimatologyPeriod(request, location)
+		if err != nil {
+			return nil, err
+		}
+		periods[location.Location] = period
+		periodFrom := time.Date(period.FromYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+		periodTo := time.Date(period.ToYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+		if from.IsZero() || periodFrom.Before(from) {
+			from = periodFrom
+		}
+		if periodTo.After(to) {
+			to = periodTo
+		}
+	}
+	if len(periods) == 0 {
+		return nil, nil
+	}
+
+	samples, err := context.Repo.GetPollenFromRanges(&dataaccess.PollenQuery{
+		From:        from,
+		To:          to,
+		PollenTypes: query.PollenTypes,
+		Locations:   query.Locations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[climatologyKey][]*dataaccess.PollenSample)
+	for _, sample := range samples {
+		period := periods[sample.Location.Location]
+		if period == nil || sample.Date.Year() < period.FromYear || sample.Date.Year() > period.ToYear {
+			continue
+		}
+		key := climatologyKey{sample.Location.Location, sample.PollenType}
+		series[key] = append(series[key], sample)
+	}
+	climatologies := make(map[climatologyKey]*analysis.Climatology)
+	for key, samples := range series {
+		climatologies[key] = analysis.CalculateClimatology(samples, periods[key.location].Window)
+	}
+	return climatologies, nil
+}
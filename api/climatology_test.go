@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// countingStore counts the queries for pollen data
+type countingStore struct {
+	*dataaccess.MemoryRepository
+	rangeQueries int
+}
+
+func (store *countingStore) GetPollenFromRange(from time.Time, to time.Time, pollenType dataaccess.PollenType, location int) ([]*dataaccess.PollenSample, error) {
+	store.rangeQueries++
+	return store.MemoryRepository.GetPollenFromRange(from, to, pollenType, location)
+}
+
+func (store *countingStore) GetPollenFromRanges(query *dataaccess.PollenQuery) ([]*dataaccess.PollenSample, error) {
+	store.rangeQueries++
+	return store.MemoryRepository.GetPollenFromRanges(query)
+}
+
+func TestPercentilesOfNormalOfSeveralSeries(t *testing.T) {
+	repo := dataaccess.NewMemoryRepository(
+		dataaccess.Location{Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen"},
+		dataaccess.Location{Location: 1, Country: "Denmark", City: "Aarhus", TimeZone: "Europe/Copenhagen"})
+	for year := 2010; year <= 2020; year++ {
+		for location := 0; location <= 1; location++ {
+			for pollenType := 0; pollenType <= 1; pollenType++ {
+				count := year - 2000
+				repo.UpsertPollenCount(&dataaccess.PollenSample{
+					Date:        time.Date(year, time.May, 1, 0, 0, 0, 0, time.UTC),
+					PollenType:  dataaccess.PollenType(pollenType),
+					Location:    dataaccess.Location{Location: location},
+					PollenCount: &count,
+				})
+			}
+		}
+	}
+	store := &countingStore{MemoryRepository: repo}
+	handler := newRouter(&httpContext{Repo: store, Config: &APIConfig{}, Clock: dataaccess.SystemClock})
+
+	for _, fill := range []string{"", "&fill=null"} {
+		store.rangeQueries = 0
+		request := httptest.NewRequest(http.MethodGet, "/api/v2/pollen?from=2020-05-01&to=2020-05-01"+
+			"&pollentype=all&location=0,1&normal=true&fromYear=2010&toYear=2019"+fill, nil)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		var groups []*PollenLocationGroupV2Dto
+		if err := json.NewDecoder(recorder.Body).Decode(&groups); err != nil {
+			t.Fatal(err)
+		}
+
+		// One query for the range and one for the climatologies
+		if store.rangeQueries != 2 {
+			t.Errorf("%d queries for pollen data, want 2", store.rangeQueries)
+		}
+		var samples int
+		for _, group := range groups {
+			for _, pollenType := range group.PollenTypes {
+				for _, sample := range pollenType.Samples {
+					samples++
+					// 20 is above every count of 2010 to 2019
+					if sample.PercentileOfNormal == nil || *sample.PercentileOfNormal != 100 {
+						t.Errorf("%v of %s: percentile of normal %v, want 100", pollenType.PollenType.ID,
+							group.Location.City, sample.PercentileOfNormal)
+					}
+				}
+			}
+		}
+		if samples != 4 {
+			t.Errorf("%d samples, want 4", samples)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// APIConfig holds configuration for the API
+type APIConfig struct {
+	// AdminAPIKey is required as a bearer token by the endpoints changing data.
+	// When it is empty, those endpoints are disabled.
+	AdminAPIKey string
+}
+
+// getConfig reads api.toml. The file is optional, and an empty configuration is used without it.
+func getConfig() *APIConfig {
+	config := &APIConfig{}
+	if _, err := os.Stat("api.toml"); os.IsNotExist(err) {
+		return config
+	}
+	if _, err := toml.DecodeFile("api.toml", config); err != nil {
+		fmt.Println(err)
+		panic(err)
+	}
+	return config
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// The DTOs of /api/v2. They are the public contract of the API, so fields are only ever added, and
+// dataaccess structs are always mapped to them explicitly rather than serialized as they are.
+
+// isoDate is the format of calendar dates in v2, e.g. "2020-05-01"
+const isoDate = "2006-01-02"
+
+// LocationV2Dto is a location. Coordinates are in degrees and elevation in meters, null when unknown.
+type LocationV2Dto struct {
+	ID        int      `json:"id"`
+	Country   string   `json:"country"`
+	City      string   `json:"city"`
+	TimeZone  string   `json:"timeZone"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Elevation *float64 `json:"elevation"`
+}
+
+// LocationInputV2Dto is the body creating or updating a location
+type LocationInputV2Dto struct {
+	Country   string   `json:"country"`
+	City      string   `json:"city"`
+	TimeZone  string   `json:"timeZone"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Elevation *float64 `json:"elevation"`
+}
+
+// LocationAvailabilityV2Dto is a location with the pollen types it has data for
+type LocationAvailabilityV2Dto struct {
+	LocationV2Dto
+	PollenTypes []*PollenAvailabilityV2Dto `json:"pollenTypes"`
+}
+
+// PollenAvailabilityV2Dto has the first and last date with data for a pollen type at a location
+type PollenAvailabilityV2Dto struct {
+	PollenType *PollenTypeRefV2Dto `json:"pollenType"`
+	FirstDate  string              `json:"firstDate"`
+	LastDate   string              `json:"lastDate"`
+}
+
+// LocationSearchV2Dto is a page of locations found by a search, best match first
+type LocationSearchV2Dto struct {
+	Locations []*LocationV2Dto `json:"locations"`
+	Total     int              `json:"total"`
+	Offset    int              `json:"offset"`
+	Limit     int              `json:"limit"`
+}
+
+// LocationDistanceV2Dto is a location and its distance in kilometers from the point searched for
+type LocationDistanceV2Dto struct {
+	Location   *LocationV2Dto `json:"location"`
+	DistanceKm float64        `json:"distanceKm"`
+}
+
+// PollenTypeV2Dto is a pollen type, its names and the thresholds of its levels, null if it has none
+type PollenTypeV2Dto struct {
+	ID         dataaccess.PollenType `json:"id"`
+	Code       string                `json:"code"`
+	Name       string                `json:"name"`
+	NameDa     string                `json:"nameDa"`
+	LatinName  string                `json:"latinName"`
+	Thresholds *ThresholdsV2Dto      `json:"thresholds"`
+}
+
+// PollenTypeRefV2Dto identifies the pollen type of data
+type PollenTypeRefV2Dto struct {
+	ID   dataaccess.PollenType `json:"id"`
+	Code string                `json:"code"`
+	Name string                `json:"name"`
+}
+
+// PollenSampleV2Dto is the measured and predicted pollen count of a date, and their levels. Counts are null
+// when unknown, and levels when the count is unknown or the pollen type has no levels.
+type PollenSampleV2Dto struct {
+	Date                 string              `json:"date"`
+	PollenType           *PollenTypeRefV2Dto `json:"pollenType"`
+	Location             *LocationV2Dto      `json:"location"`
+	PollenCount          *int                `json:"pollenCount"`
+	PredictedPollenCount *float32            `json:"predictedPollenCount"`
+	Level                *string             `json:"level"`
+	PredictedLevel       *string             `json:"predictedLevel"`
+	// PercentileOfNormal is the percentile of pollenCount in the climatology of the date. It is only
+	// included when asked for.
+	PercentileOfNormal *float64 `json:"percentileOfNormal,omitempty"`
+	// Status is measured, missing or filled. It is only included when the range is filled.
+	Status *string `json:"status,omitempty"`
+	// SmoothedPollenCount is the moving average of pollenCount. It is only included when asked for.
+	SmoothedPollenCount *float64 `json:"smoothedPollenCount,omitempty"`
+}
+
+// PollenForecastV2Dto is a forecast for a date, as issued at a time
+type PollenForecastV2Dto struct {
+	TargetDate           string              `json:"targetDate"`
+	PollenType           *PollenTypeRefV2Dto `json:"pollenType"`
+	Location             *LocationV2Dto      `json:"location"`
+	IssuedAt             time.Time           `json:"issuedAt"`
+	LeadDays             int                 `json:"leadDays"`
+	PredictedPollenCount float32             `json:"predictedPollenCount"`
+}
+
+// FeedStationV2Dto maps a location and pollen type to a station of the astma-allergi.dk feed
+type FeedStationV2Dto struct {
+	Location   int                   `json:"location"`
+	PollenType dataaccess.PollenType `json:"pollenType"`
+	StationID  int                   `json:"stationId"`
+	TypeID     int                   `json:"typeId"`
+}
+
+// FeedStationInputV2Dto is the body setting a feed station. typeId defaults to the one of the pollen type.
+type FeedStationInputV2Dto struct {
+	StationID int `json:"stationId"`
+	TypeID    int `json:"typeId"`
+}
+
+// AccuracyV2Dto compares the predicted pollen counts of a period to the measured ones, in total and per month
+type AccuracyV2Dto struct {
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	PollenType *PollenTypeRefV2Dto `json:"pollenType"`
+	Location   *LocationV2Dto      `json:"location"`
+	Thresholds *ThresholdsV2Dto    `json:"thresholds"`
+	AccuracyMetricsV2Dto
+	Months []*MonthAccuracyV2Dto `json:"months"`
+}
+
+// ThresholdsV2Dto are the lowest counts of the moderate, high and very high levels. Lower counts are low.
+type ThresholdsV2Dto struct {
+	Moderate float64 `json:"moderate"`
+	High     float64 `json:"high"`
+	VeryHigh float64 `json:"veryHigh"`
+}
+
+// LevelsV2Dto describes the levels, and the counts of each level per pollen type
+type LevelsV2Dto struct {
+	Levels      []*LevelV2Dto           `json:"levels"`
+	PollenTypes []*PollenTypeBandsV2Dto `json:"pollenTypes"`
+}
+
+// LevelV2Dto names and describes a level in English and Danish
+type LevelV2Dto struct {
+	Level         string `json:"level"`
+	Name          string `json:"name"`
+	NameDa        string `json:"nameDa"`
+	Description   string `json:"description"`
+	DescriptionDa string `json:"descriptionDa"`
+}
+
+// PollenTypeBandsV2Dto has the counts of each level of a pollen type
+type PollenTypeBandsV2Dto struct {
+	PollenType *PollenTypeRefV2Dto `json:"pollenType"`
+	Bands      []*LevelBandV2Dto   `json:"bands"`
+}
+
+// LevelBandV2Dto is the range of counts of a level, from included to excluded. to is null for the highest level.
+type LevelBandV2Dto struct {
+	Level string   `json:"level"`
+	From  float64  `json:"from"`
+	To    *float64 `json:"to"`
+}
+
+// AccuracyMetricsV2Dto are the errors of the days with both a measured and a predicted count, null when there are none
+type AccuracyMetricsV2Dto struct {
+	Count      int                     `json:"count"`
+	MAE        *float64                `json:"mae"`
+	RMSE       *float64                `json:"rmse"`
+	Bias       *float64                `json:"bias"`
+	HitRate    *float64                `json:"hitRate"`
+	Categories []*CategoryHitRateV2Dto `json:"categories"`
+	Baseline   *BaselineAccuracyV2Dto  `json:"baseline"`
+}
+
+// CategoryHitRateV2Dto is the share of the days measured at a risk level that were predicted at that level
+type CategoryHitRateV2Dto struct {
+	Level   string   `json:"level"`
+	Count   int      `json:"count"`
+	Hits    int      `json:"hits"`
+	HitRate *float64 `json:"hitRate"`
+}
+
+// BaselineAccuracyV2Dto is the accuracy of predicting yesterday's count, and the skill of the prediction over it
+type BaselineAccuracyV2Dto struct {
+	Count int      `json:"count"`
+	MAE   *float64 `json:"mae"`
+	RMSE  *float64 `json:"rmse"`
+	Skill *float64 `json:"skill"`
+}
+
+// MonthAccuracyV2Dto is the accuracy of a month, e.g. "2020-05"
+type MonthAccuracyV2Dto struct {
+	Month string `json:"month"`
+	AccuracyMetricsV2Dto
+}
+
+// SeasonV2Dto is the pollen season of a year. Dates are null until they are detected.
+type SeasonV2Dto struct {
+	Year       int                 `json:"year"`
+	PollenType *PollenTypeRefV2Dto `json:"pollenType"`
+	Location   *LocationV2Dto      `json:"location"`
+	Method     string              `json:"method"`
+	Percent    *float64            `json:"percent,omitempty"`
+	Threshold  *float64            `json:"threshold,omitempty"`
+	Days       *int                `json:"days,omitempty"`
+	// Complete tells if the year is over in the calendar of the location
+	Complete     bool    `json:"complete"`
+	Start        *string `json:"start"`
+	Peak         *string `json:"peak"`
+	PeakCount    *int    `json:"peakCount"`
+	End          *string `json:"end"`
+	Ended        bool    `json:"ended"`
+	TotalLoad    int     `json:"totalLoad"`
+	HighDays     *int    `json:"highDays"`
+	MeasuredDays int     `json:"measuredDays"`
+}
+
+// ClimatologyV2Dto is the normal pollen count of every day of year with measured counts in the reference period
+type ClimatologyV2Dto struct {
+	PollenType *PollenTypeRefV2Dto    `json:"pollenType"`
+	Location   *LocationV2Dto         `json:"location"`
+	FromYear   int                    `json:"fromYear"`
+	ToYear     int                    `json:"toYear"`
+	Window     int                    `json:"window"`
+	Days       []*ClimatologyDayV2Dto `json:"days"`
+}
+
+// ClimatologyDayV2Dto is the distribution of the counts of a day of year, e.g. "05-01"
+type ClimatologyDayV2Dto struct {
+	Day    string  `json:"day"`
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P10    float64 `json:"p10"`
+	P25    float64 `json:"p25"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+}
+
+// PollenAggregateV2Dto sums up the pollen data of the dates from start to end. mean, max and predictedMean are
+// null when there are no measured or predicted counts.
+type PollenAggregateV2Dto struct {
+	Start         string              `json:"start"`
+	End           string              `json:"end"`
+	PollenType    *PollenTypeRefV2Dto `json:"pollenType"`
+	Location      *LocationV2Dto      `json:"location"`
+	MeasuredDays  int                 `json:"measuredDays"`
+	Sum           int                 `json:"sum"`
+	Mean          *float64            `json:"mean"`
+	Max           *int                `json:"max"`
+	PredictedDays int                 `json:"predictedDays"`
+	PredictedMean *float64            `json:"predictedMean"`
+}
+
+// PollenLocationGroupV2Dto is the pollen data of a location, grouped by pollen type
+type PollenLocationGroupV2Dto struct {
+	Location    *LocationV2Dto          `json:"location"`
+	PollenTypes []*PollenTypeGroupV2Dto `json:"pollenTypes"`
+}
+
+// PollenTypeGroupV2Dto is the pollen data of a pollen type at a location. It has either samples, or
+// aggregates when an interval was asked for.
+type PollenTypeGroupV2Dto struct {
+	PollenType *PollenTypeRefV2Dto     `json:"pollenType"`
+	Samples    []*PollenSampleV2Dto    `json:"samples,omitempty"`
+	Aggregates []*PollenAggregateV2Dto `json:"aggregates,omitempty"`
+}
+
+// pollenTypeRefs looks up the names and thresholds of pollen types by id
+type pollenTypeRefs map[dataaccess.PollenType]*dataaccess.PollenTypeDefinition
+
+// ref returns the reference to a pollen type. Unknown types only have an id.
+func (refs pollenTypeRefs) ref(pollenType dataaccess.PollenType) *PollenTypeRefV2Dto {
+	if definition, ok := refs[pollenType]; ok {
+		return &PollenTypeRefV2Dto{
+			ID:   definition.PollenType,
+			Code: definition.Code,
+			Name: definition.NameEn,
+		}
+	}
+	return &PollenTypeRefV2Dto{ID: pollenType}
+}
+
+// thresholds returns the thresholds of a pollen type. Unknown types have no levels.
+func (refs pollenTypeRefs) thresholds(pollenType dataaccess.PollenType) dataaccess.Thresholds {
+	if definition, ok := refs[pollenType]; ok {
+		return definition.Thresholds
+	}
+	return dataaccess.Thresholds{}
+}
+
+func toPollenTypeRefs(pollenTypes []*dataaccess.PollenTypeDefinition) pollenTypeRefs {
+	refs := make(pollenTypeRefs)
+	for _, pollenType := range pollenTypes {
+		refs[pollenType.PollenType] = pollenType
+	}
+	return refs
+}
+
+func toLocationV2(location *dataaccess.Location) *LocationV2Dto {
+	return &LocationV2Dto{
+		ID:        location.Location,
+		Country:   location.Country,
+		City:      location.City,
+		TimeZone:  location.TimeZone,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Elevation: location.Elevation,
+	}
+}
+
+func toLocationsV2(locations []*dataaccess.Location) []*LocationV2Dto {
+	results := make([]*LocationV2Dto, len(locations))
+	for i, location := range locations {
+		results[i] = toLocationV2(location)
+	}
+	return results
+}
+
+// toLocation maps the body of a request to a location
+func (input *LocationInputV2Dto) toLocation() *dataaccess.Location {
+	return &dataaccess.Location{
+		Country:   input.Country,
+		City:      input.City,
+		TimeZone:  input.TimeZone,
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
+		Elevation: input.Elevation,
+	}
+}
+
+func toPollenTypeV2(pollenType *dataaccess.PollenTypeDefinition) *PollenTypeV2Dto {
+	return &PollenTypeV2Dto{
+		ID:         pollenType.PollenType,
+		Code:       pollenType.Code,
+		Name:       pollenType.NameEn,
+		NameDa:     pollenType.NameDa,
+		LatinName:  pollenType.LatinName,
+		Thresholds: toThresholdsV2(pollenType.Thresholds),
+	}
+}
+
+// toThresholdsV2 maps thresholds, or returns nil if they aren't defined
+func toThresholdsV2(thresholds dataaccess.Thresholds) *ThresholdsV2Dto {
+	if !thresholds.Defined() {
+		return nil
+	}
+	return &ThresholdsV2Dto{Moderate: thresholds.Moderate, High: thresholds.High, VeryHigh: thresholds.VeryHigh}
+}
+
+// toLevel returns the level of a count, or nil if the count is unknown or there are no levels
+func toLevel(count *float64, thresholds dataaccess.Thresholds) *string {
+	if count == nil || !thresholds.Defined() {
+		return nil
+	}
+	level := thresholds.Level(*count).String()
+	return &level
+}
+
+func toLevelsV2(pollenTypes []*dataaccess.PollenTypeDefinition) *LevelsV2Dto {
+	result := &LevelsV2Dto{
+		Levels:      make([]*LevelV2Dto, len(dataaccess.LevelDescriptions)),
+		PollenTypes: []*PollenTypeBandsV2Dto{},
+	}
+	for i, description := range dataaccess.LevelDescriptions {
+		result.Levels[i] = &LevelV2Dto{
+			Level:         description.Level.String(),
+			Name:          description.NameEn,
+			NameDa:        description.NameDa,
+			Description:   description.DescriptionEn,
+			DescriptionDa: description.DescriptionDa,
+		}
+	}
+	refs := toPollenTypeRefs(pollenTypes)
+	for _, pollenType := range pollenTypes {
+		thresholds := pollenType.Thresholds
+		if !thresholds.Defined() {
+			continue
+		}
+		bounds := []float64{0, thresholds.Moderate, thresholds.High, thresholds.VeryHigh}
+		bands := make([]*LevelBandV2Dto, len(dataaccess.Levels))
+		for i, level := range dataaccess.Levels {
+			bands[i] = &LevelBandV2Dto{Level: level.String(), From: bounds[i]}
+			if i+1 < len(bounds) {
+				bands[i].To = &bounds[i+1]
+			}
+		}
+		result.PollenTypes = append(result.PollenTypes, &PollenTypeBandsV2Dto{
+			PollenType: refs.ref(pollenType.PollenType),
+			Bands:      bands,
+		})
+	}
+	return result
+}
+
+func toPollenSampleV2(sample *dataaccess.PollenSample, refs pollenTypeRefs) *PollenSampleV2Dto {
+	var pollenCount, predictedPollenCount *float64
+	if sample.PollenCount != nil {
+		count := float64(*sample.PollenCount)
+		pollenCount = &count
+	}
+	if sample.PredictedPollenCount != nil {
+		count := float64(*sample.PredictedPollenCount)
+		predictedPollenCount = &count
+	}
+	thresholds := refs.thresholds(sample.PollenType)
+	return &PollenSampleV2Dto{
+		Date:                 sample.Date.Format(isoDate),
+		PollenType:           refs.ref(sample.PollenType),
+		Location:             toLocationV2(&sample.Location),
+		PollenCount:          sample.PollenCount,
+		PredictedPollenCount: sample.PredictedPollenCount,
+		Level:                toLevel(pollenCount, thresholds),
+		PredictedLevel:       toLevel(predictedPollenCount, thresholds),
+	}
+}
+
+// toSeriesDayV2 maps a day of a resampled series to a sample with its status and moving average
+func toSeriesDayV2(day *analysis.SeriesDay, refs pollenTypeRefs) *PollenSampleV2Dto {
+	result := toPollenSampleV2(day.Sample, refs)
+	if day.Status != "" {
+		status := string(day.Status)
+		result.Status = &status
+	}
+	result.SmoothedPollenCount = day.Smoothed
+	return result
+}
+
+func toPollenForecastV2(forecast *dataaccess.PollenForecast, location *dataaccess.Location, refs pollenTypeRefs) *PollenForecastV2Dto {
+	return &PollenForecastV2Dto{
+		TargetDate:           forecast.TargetDate.Format(isoDate),
+		PollenType:           refs.ref(forecast.PollenType),
+		Location:             toLocationV2(location),
+		IssuedAt:             forecast.IssuedAt,
+		LeadDays:             forecast.LeadDays,
+		PredictedPollenCount: forecast.PredictedPollenCount,
+	}
+}
+
+func toFeedStationV2(station *dataaccess.FeedStation) *FeedStationV2Dto {
+	return &FeedStationV2Dto{
+		Location:   station.Location,
+		PollenType: station.PollenType,
+		StationID:  station.StationID,
+		TypeID:     station.TypeID,
+	}
+}
+
+func toAccuracyMetricsV2(metrics *analysis.Metrics) AccuracyMetricsV2Dto {
+	categories := make([]*CategoryHitRateV2Dto, len(metrics.Categories))
+	for i, category := range metrics.Categories {
+		categories[i] = &CategoryHitRateV2Dto{
+			Level:   category.Level.String(),
+			Count:   category.Count,
+			Hits:    category.Hits,
+			HitRate: category.HitRate,
+		}
+	}
+	return AccuracyMetricsV2Dto{
+		Count:      metrics.Count,
+		MAE:        metrics.MAE,
+		RMSE:       metrics.RMSE,
+		Bias:       metrics.Bias,
+		HitRate:    metrics.HitRate,
+		Categories: categories,
+		Baseline: &BaselineAccuracyV2Dto{
+			Count: metrics.Baseline.Count,
+			MAE:   metrics.Baseline.MAE,
+			RMSE:  metrics.Baseline.RMSE,
+			Skill: metrics.Baseline.Skill,
+		},
+	}
+}
+
+func toAccuracyV2(accuracy *analysis.Accuracy, query *pollenRangeQuery, location *dataaccess.Location,
+	refs pollenTypeRefs) *AccuracyV2Dto {
+	months := make([]*MonthAccuracyV2Dto, len(accuracy.Months))
+	for i, month := range accuracy.Months {
+		months[i] = &MonthAccuracyV2Dto{
+			Month:                month.Month.Format("2006-01"),
+			AccuracyMetricsV2Dto: toAccuracyMetricsV2(&month.Metrics),
+		}
+	}
+	return &AccuracyV2Dto{
+		From:                 query.From.Format(isoDate),
+		To:                   query.To.Format(isoDate),
+		PollenType:           refs.ref(query.PollenType),
+		Location:             toLocationV2(location),
+		Thresholds:           toThresholdsV2(refs.thresholds(query.PollenType)),
+		AccuracyMetricsV2Dto: toAccuracyMetricsV2(&accuracy.Metrics),
+		Months:               months,
+	}
+}
+
+// toDateV2 formats a date as an ISO date, or returns nil if there is none
+func toDateV2(date *time.Time) *string {
+	if date == nil {
+		return nil
+	}
+	result := date.Format(isoDate)
+	return &result
+}
+
+func toSeasonV2(season *analysis.Season, year int, options *analysis.SeasonOptions,
+	pollenType *PollenTypeRefV2Dto, location *dataaccess.Location) *SeasonV2Dto {
+	result := &SeasonV2Dto{
+		Year:         year,
+		PollenType:   pollenType,
+		Location:     toLocationV2(location),
+		Method:       string(options.Method),
+		Complete:     options.Complete,
+		Start:        toDateV2(season.Start),
+		Peak:         toDateV2(season.Peak),
+		PeakCount:    season.PeakCount,
+		End:          toDateV2(season.End),
+		Ended:        season.Ended,
+		TotalLoad:    season.TotalLoad,
+		HighDays:     season.HighDays,
+		MeasuredDays: season.MeasuredDays,
+	}
+	if options.Method == analysis.SeasonCumulative {
+		result.Percent = &options.Percent
+	} else {
+		result.Threshold = &options.Threshold
+		result.Days = &options.Days
+	}
+	return result
+}
+
+func toClimatologyV2(climatology *analysis.Climatology, period *climatologyPeriod,
+	pollenType *PollenTypeRefV2Dto, location *dataaccess.Location) *ClimatologyV2Dto {
+	days := make([]*ClimatologyDayV2Dto, len(climatology.Days))
+	for i, day := range climatology.Days {
+		days[i] = &ClimatologyDayV2Dto{
+			Day:    fmt.Sprintf("%02d-%02d", int(day.Month), day.Day),
+			Count:  day.Count,
+			Mean:   day.Mean,
+			Median: day.Median,
+			P10:    day.P10,
+			P25:    day.P25,
+			P75:    day.P75,
+			P90:    day.P90,
+		}
+	}
+	return &ClimatologyV2Dto{
+		PollenType: pollenType,
+		Location:   toLocationV2(location),
+		FromYear:   period.FromYear,
+		ToYear:     period.ToYear,
+		Window:     period.Window,
+		Days:       days,
+	}
+}
+
+func toPollenAggregateV2(aggregate *dataaccess.PollenAggregate, refs pollenTypeRefs) *PollenAggregateV2Dto {
+	return &PollenAggregateV2Dto{
+		Start:         aggregate.Start.Format(isoDate),
+		End:           aggregate.End.Format(isoDate),
+		PollenType:    refs.ref(aggregate.PollenType),
+		Location:      toLocationV2(&aggregate.Location),
+		MeasuredDays:  aggregate.MeasuredDays,
+		Sum:           aggregate.Sum,
+		Mean:          aggregate.Mean,
+		Max:           aggregate.Max,
+		PredictedDays: aggregate.PredictedDays,
+		PredictedMean: aggregate.PredictedMean,
+	}
+}
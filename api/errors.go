@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// Error codes of ErrorDto
+const (
+	codeInvalidParameter = "invalid_parameter"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeNotAcceptable    = "not_acceptable"
+	codeInternal         = "internal_error"
+)
+
+// requestIDHeader carries the id of a request, given by the client or generated
+const requestIDHeader = "X-Request-ID"
+
+// ErrorDto is the body of every error response
+type ErrorDto struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Field is the parameter or body field that was invalid, if any
+	Field     string `json:"field,omitempty"`
+	RequestID string `json:"requestId"`
+}
+
+// apiError is an error with the status and code it is responded with
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Field   string
+}
+
+func (err *apiError) Error() string {
+	return err.Message
+}
+
+// invalidParameter is a 400 error for a parameter or body field
+func invalidParameter(field string, err error) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: codeInvalidParameter, Message: err.Error(), Field: field}
+}
+
+// notFound is a 404 error
+func notFound(message string) *apiError {
+	return &apiError{Status: http.StatusNotFound, Code: codeNotFound, Message: message}
+}
+
+// toAPIError maps an error to the response for it. Errors of the repository are mapped by type, and
+// anything unknown is an internal error, whose details are logged rather than returned.
+func toAPIError(err error, requestID string) *apiError {
+	var result *apiError
+	switch {
+	case errors.As(err, &result):
+		return result
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound("Object not found")
+	case errors.Is(err, dataaccess.ErrLocationInUse):
+		return &apiError{Status: http.StatusConflict, Code: codeConflict,
+			Message: "Location has pollen data, use cascade=true to delete it as well"}
+	case errors.Is(err, dataaccess.ErrLocationIDTaken):
+		return &apiError{Status: http.StatusConflict, Code: codeConflict,
+			Message: "Other locations were created at the same time, try again"}
+	case errors.Is(err, dataaccess.ErrMissingFeedTypeID):
+		return &apiError{Status: http.StatusBadRequest, Code: codeInvalidParameter, Message: err.Error(), Field: "TypeID"}
+	}
+	log.Printf("Request %s failed: %v", requestID, err)
+	return &apiError{Status: http.StatusInternalServerError, Code: codeInternal, Message: "Internal server error"}
+}
+
+// writeError writes err as an ErrorDto with the status matching it
+func writeError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	requestID := getRequestID(request)
+	result := toAPIError(err, requestID)
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(result.Status)
+	json.NewEncoder(responseWriter).Encode(&ErrorDto{
+		Code:      result.Code,
+		Message:   result.Message,
+		Field:     result.Field,
+		RequestID: requestID,
+	})
+}
+
+// Handlers for requests not matching any route
+func notFoundHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	writeError(responseWriter, request, notFound("No such endpoint"))
+}
+
+func methodNotAllowedHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	writeError(responseWriter, request, &apiError{Status: http.StatusMethodNotAllowed, Code: codeMethodNotAllowed,
+		Message: "Method not allowed"})
+}
+
+type requestIDKey struct{}
+
+// requestIDMiddleware gives every request an id, which is returned in the X-Request-ID header and in errors.
+// A valid id sent by the client is kept, so requests can be traced across services.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+// getRequestID returns the id given to a request by requestIDMiddleware
+func getRequestID(request *http.Request) string {
+	requestID, _ := request.Context().Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID accepts short ids of letters, digits, dashes and underscores
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
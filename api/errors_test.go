@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+const testAdminAPIKey = "test-key"
+
+// newTestServer serves the API like main does, backed by a MemoryRepository with the location Copenhagen
+func newTestServer(repo *dataaccess.MemoryRepository) http.Handler {
+	context := &httpContext{Repo: repo, Config: &APIConfig{AdminAPIKey: testAdminAPIKey}, Clock: dataaccess.SystemClock}
+	return requestIDMiddleware(trailingSlashMiddleware(newRouter(context)))
+}
+
+func newTestRepository() *dataaccess.MemoryRepository {
+	return dataaccess.NewMemoryRepository(dataaccess.Location{
+		Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen",
+	})
+}
+
+// serveError sends a request as the admin and decodes the error it is answered with
+func serveError(t *testing.T, handler http.Handler, method string, url string, requestID string) (*httptest.ResponseRecorder, *ErrorDto) {
+	t.Helper()
+	request := httptest.NewRequest(method, url, nil)
+	request.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+	if requestID != "" {
+		request.Header.Set(requestIDHeader, requestID)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	var result ErrorDto
+	if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return recorder, &result
+}
+
+func TestErrorResponses(t *testing.T) {
+	repo := newTestRepository()
+	count := 12
+	repo.UpsertPollenCount(&dataaccess.PollenSample{
+		Date: time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC), Location: dataaccess.Location{Location: 0}, PollenCount: &count,
+	})
+	handler := newTestServer(repo)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		status int
+		code   string
+		field  string
+	}{
+		{"invalid pollen type", http.MethodGet, "/api/v2/pollen?from=2020-05-01&to=2020-05-02&pollentype=grass&location=0",
+			http.StatusBadRequest, codeInvalidParameter, "pollentype"},
+		{"invalid pollen type v1", http.MethodGet, "/api/pollen?from=2020-05-01&to=2020-05-02&pollentype=grass&location=0",
+			http.StatusBadRequest, codeInvalidParameter, "pollentype"},
+		{"unknown location", http.MethodGet, "/api/v2/location/7", http.StatusNotFound, codeNotFound, ""},
+		{"unknown location of pollen", http.MethodGet, "/api/v2/pollen/2020-05-01?pollentype=0&location=7",
+			http.StatusNotFound, codeNotFound, ""},
+		{"location in use", http.MethodDelete, "/api/v2/location/0", http.StatusConflict, codeConflict, ""},
+		{"unknown endpoint", http.MethodGet, "/api/v2/nothing", http.StatusNotFound, codeNotFound, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder, result := serveError(t, handler, test.method, test.url, "")
+			if recorder.Code != test.status {
+				t.Errorf("status %d, want %d", recorder.Code, test.status)
+			}
+			if result.Code != test.code {
+				t.Errorf("code %q, want %q", result.Code, test.code)
+			}
+			if result.Field != test.field {
+				t.Errorf("field %q, want %q", result.Field, test.field)
+			}
+			if result.Message == "" {
+				t.Error("no message")
+			}
+			requestID := recorder.Header().Get(requestIDHeader)
+			if requestID == "" || result.RequestID != requestID {
+				t.Errorf("request id %q in the body, %q in the header", result.RequestID, requestID)
+			}
+		})
+	}
+}
+
+func TestErrorRequestID(t *testing.T) {
+	handler := newTestServer(newTestRepository())
+
+	recorder, result := serveError(t, handler, http.MethodGet, "/api/v2/location/7", "trace-42")
+	if header := recorder.Header().Get(requestIDHeader); header != "trace-42" || result.RequestID != "trace-42" {
+		t.Errorf("request id %q in the body, %q in the header, want trace-42", result.RequestID, header)
+	}
+
+	// Invalid ids are replaced
+	recorder, result = serveError(t, handler, http.MethodGet, "/api/v2/location/7", "not a valid id")
+	if header := recorder.Header().Get(requestIDHeader); header == "not a valid id" || result.RequestID != header {
+		t.Errorf("request id %q in the body, %q in the header", result.RequestID, header)
+	}
+}
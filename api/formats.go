@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// Output formats of the pollen endpoints of v2
+const (
+	formatJSON      = "json"
+	formatCSV       = "csv"
+	formatNDJSON    = "ndjson"
+	formatICalendar = "ics"
+)
+
+// formatMediaTypes are the media types of each format, the first being the one responded with
+var formatMediaTypes = map[string][]string{
+	formatJSON:      {"application/json"},
+	formatCSV:       {"text/csv"},
+	formatNDJSON:    {"application/x-ndjson", "application/ndjson"},
+	formatICalendar: {"text/calendar"},
+}
+
+var errInvalidFormat = invalidParameter("format", errors.New("format must be json, csv, ndjson or ics"))
+
+var errIntervalFormat = invalidParameter("format", errors.New("format must be json, csv or ndjson with interval"))
+
+var errNotAcceptable = &apiError{Status: http.StatusNotAcceptable, Code: codeNotAcceptable,
+	Message: "Accept must allow application/json, text/csv, application/x-ndjson or text/calendar"}
+
+// negotiateFormat picks the output format of a request from the query parameter format or, without it,
+// the Accept header. JSON is used when neither asks for anything else.
+func negotiateFormat(request *http.Request) (string, error) {
+	if format := request.FormValue("format"); format != "" {
+		if _, ok := formatMediaTypes[format]; !ok {
+			return "", errInvalidFormat
+		}
+		return format, nil
+	}
+
+	accept := request.Header.Get("Accept")
+	if accept == "" {
+		return formatJSON, nil
+	}
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		format := mediaTypeFormat(mediaType)
+		if format != "" && quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+	if best == "" {
+		return "", errNotAcceptable
+	}
+	return best, nil
+}
+
+// mediaTypeFormat returns the format of a media type of the Accept header, or "" if it isn't supported.
+// Text is only matched by its exact media types, so text/* and e.g. text/plain aren't supported.
+func mediaTypeFormat(mediaType string) string {
+	switch mediaType {
+	case "*/*", "application/*":
+		return formatJSON
+	}
+	for format, mediaTypes := range formatMediaTypes {
+		for _, supported := range mediaTypes {
+			if mediaType == supported {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// writeSamples writes pollen samples in the format of the request
+func (context *httpContext) writeSamples(responseWriter http.ResponseWriter, request *http.Request, format string, samples []*PollenSampleV2Dto) {
+	responseWriter.Header().Set("Content-Type", formatMediaTypes[format][0]+"; charset=utf-8")
+	switch format {
+	case formatCSV:
+		writeSamplesCSV(responseWriter, samples)
+	case formatNDJSON:
+		writeSamplesNDJSON(responseWriter, samples)
+	case formatICalendar:
+		writeSamplesICalendar(responseWriter, samples, context.Clock.Now())
+	default:
+		writeObject(responseWriter, request, samples, nil)
+	}
+}
+
+// samplesCSVHeader is the header row of pollen samples as CSV
+var samplesCSVHeader = []string{
+	"date", "pollenTypeId", "pollenType", "locationId", "country", "city", "pollenCount", "predictedPollenCount",
+	"level", "predictedLevel", "percentileOfNormal", "status", "smoothedPollenCount",
+}
+
+// writeSamplesCSV writes samples as CSV with a header row. Unknown counts and levels are empty, and so are
+// percentileOfNormal, status and smoothedPollenCount unless they were asked for.
+func writeSamplesCSV(responseWriter http.ResponseWriter, samples []*PollenSampleV2Dto) {
+	writer := csv.NewWriter(responseWriter)
+	writer.Write(samplesCSVHeader)
+	for _, sample := range samples {
+		pollenCount, predictedPollenCount, percentileOfNormal, smoothedPollenCount := "", "", "", ""
+		if sample.PollenCount != nil {
+			pollenCount = strconv.Itoa(*sample.PollenCount)
+		}
+		if sample.PredictedPollenCount != nil {
+			predictedPollenCount = strconv.FormatFloat(float64(*sample.PredictedPollenCount), 'f', -1, 32)
+		}
+		if sample.PercentileOfNormal != nil {
+			percentileOfNormal = strconv.FormatFloat(*sample.PercentileOfNormal, 'f', 1, 64)
+		}
+		if sample.SmoothedPollenCount != nil {
+			smoothedPollenCount = strconv.FormatFloat(*sample.SmoothedPollenCount, 'f', -1, 64)
+		}
+		writer.Write([]string{
+			sample.Date,
+			strconv.Itoa(int(sample.PollenType.ID)),
+			sample.PollenType.Code,
+			strconv.Itoa(sample.Location.ID),
+			sample.Location.Country,
+			sample.Location.City,
+			pollenCount,
+			predictedPollenCount,
+			stringOrEmpty(sample.Level),
+			stringOrEmpty(sample.PredictedLevel),
+			percentileOfNormal,
+			stringOrEmpty(sample.Status),
+			smoothedPollenCount,
+		})
+	}
+	writer.Flush()
+}
+
+// writeSamplesNDJSON writes samples as one JSON object per line, flushing each line
+func writeSamplesNDJSON(responseWriter http.ResponseWriter, samples []*PollenSampleV2Dto) {
+	output := json.NewEncoder(responseWriter)
+	flusher, _ := responseWriter.(http.Flusher)
+	for _, sample := range samples {
+		output.Encode(sample)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// writeAggregates writes pollen aggregates in the format of the request. They have no iCalendar format.
+func (context *httpContext) writeAggregates(responseWriter http.ResponseWriter, request *http.Request, format string, aggregates []*PollenAggregateV2Dto) {
+	responseWriter.Header().Set("Content-Type", formatMediaTypes[format][0]+"; charset=utf-8")
+	switch format {
+	case formatCSV:
+		writeAggregatesCSV(responseWriter, aggregates)
+	case formatNDJSON:
+		writeAggregatesNDJSON(responseWriter, aggregates)
+	default:
+		writeObject(responseWriter, request, aggregates, nil)
+	}
+}
+
+// writeAggregatesNDJSON writes aggregates as one JSON object per line, flushing each line
+func writeAggregatesNDJSON(responseWriter http.ResponseWriter, aggregates []*PollenAggregateV2Dto) {
+	output := json.NewEncoder(responseWriter)
+	flusher, _ := responseWriter.(http.Flusher)
+	for _, aggregate := range aggregates {
+		output.Encode(aggregate)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// aggregatesCSVHeader is the header row of pollen aggregates as CSV
+var aggregatesCSVHeader = []string{
+	"start", "end", "pollenTypeId", "pollenType", "locationId", "country", "city",
+	"measuredDays", "sum", "mean", "max", "predictedDays", "predictedMean",
+}
+
+// writeAggregatesCSV writes aggregates as CSV with a header row. Means and maximums without counts are empty.
+func writeAggregatesCSV(responseWriter http.ResponseWriter, aggregates []*PollenAggregateV2Dto) {
+	writer := csv.NewWriter(responseWriter)
+	writer.Write(aggregatesCSVHeader)
+	for _, aggregate := range aggregates {
+		mean, max, predictedMean := "", "", ""
+		if aggregate.Mean != nil {
+			mean = strconv.FormatFloat(*aggregate.Mean, 'f', -1, 64)
+		}
+		if aggregate.Max != nil {
+			max = strconv.Itoa(*aggregate.Max)
+		}
+		if aggregate.PredictedMean != nil {
+			predictedMean = strconv.FormatFloat(*aggregate.PredictedMean, 'f', -1, 64)
+		}
+		writer.Write([]string{
+			aggregate.Start,
+			aggregate.End,
+			strconv.Itoa(int(aggregate.PollenType.ID)),
+			aggregate.PollenType.Code,
+			strconv.Itoa(aggregate.Location.ID),
+			aggregate.Location.Country,
+			aggregate.Location.City,
+			strconv.Itoa(aggregate.MeasuredDays),
+			strconv.Itoa(aggregate.Sum),
+			mean,
+			max,
+			strconv.Itoa(aggregate.PredictedDays),
+			predictedMean,
+		})
+	}
+	writer.Flush()
+}
+
+// writeSamplesICalendar writes samples as an iCalendar feed with an all-day event per date with a count,
+// which calendar apps can subscribe to. The measured count is used when known, otherwise the prediction.
+func writeSamplesICalendar(responseWriter http.ResponseWriter, samples []*PollenSampleV2Dto, now time.Time) {
+	writer := bufio.NewWriter(responseWriter)
+	writeICalendarLine(writer, "BEGIN:VCALENDAR")
+	writeICalendarLine(writer, "VERSION:2.0")
+	writeICalendarLine(writer, "PRODID:-//Tomorrows pollen today//Pollen API//EN")
+	writeICalendarLine(writer, "CALSCALE:GREGORIAN")
+	writeICalendarLine(writer, "X-WR-CALNAME:"+escapeICalendarText(iCalendarName(samples)))
+	for _, sample := range samples {
+		summary := sampleSummary(sample)
+		if summary == "" {
+			continue
+		}
+		date, err := time.Parse(isoDate, sample.Date)
+		if err != nil {
+			continue
+		}
+		writeICalendarLine(writer, "BEGIN:VEVENT")
+		writeICalendarLine(writer, fmt.Sprintf("UID:%s-%v-%v@tomorrowspollen.today",
+			date.Format("20060102"), sample.PollenType.ID, sample.Location.ID))
+		writeICalendarLine(writer, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+		writeICalendarLine(writer, "DTSTART;VALUE=DATE:"+date.Format("20060102"))
+		writeICalendarLine(writer, "DTEND;VALUE=DATE:"+date.AddDate(0, 0, 1).Format("20060102"))
+		writeICalendarLine(writer, "SUMMARY:"+escapeICalendarText(summary))
+		writeICalendarLine(writer, "TRANSP:TRANSPARENT")
+		writeICalendarLine(writer, "END:VEVENT")
+	}
+	writeICalendarLine(writer, "END:VCALENDAR")
+	writer.Flush()
+}
+
+// iCalendarName names a calendar after the pollen type and location of its samples, if they have only one of each
+func iCalendarName(samples []*PollenSampleV2Dto) string {
+	if len(samples) == 0 {
+		return "Pollen"
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	if first.PollenType.ID != last.PollenType.ID || first.Location.ID != last.Location.ID {
+		return "Pollen"
+	}
+	return fmt.Sprintf("%s pollen in %s", pollenTypeName(first.PollenType), first.Location.City)
+}
+
+// sampleSummary is the summary of the event of a sample, or "" if it has no count. It leads with the level
+// when the pollen type has levels, e.g. "Grass pollen: moderate (12)". Filled counts weren't measured, so
+// they are left out.
+func sampleSummary(sample *PollenSampleV2Dto) string {
+	name := pollenTypeName(sample.PollenType)
+	measured := sample.PollenCount != nil && (sample.Status == nil || *sample.Status != string(analysis.DayFilled))
+	switch {
+	case measured && sample.Level != nil:
+		return fmt.Sprintf("%s pollen: %s (%v)", name, levelName(*sample.Level), *sample.PollenCount)
+	case measured:
+		return fmt.Sprintf("%s pollen: %v", name, *sample.PollenCount)
+	case sample.PredictedPollenCount != nil && sample.PredictedLevel != nil:
+		return fmt.Sprintf("%s pollen: %s (%.0f, forecast)", name, levelName(*sample.PredictedLevel), *sample.PredictedPollenCount)
+	case sample.PredictedPollenCount != nil:
+		return fmt.Sprintf("%s pollen: %.0f (forecast)", name, *sample.PredictedPollenCount)
+	}
+	return ""
+}
+
+// levelName returns the lowercase English name of a level, e.g. "very high" for very_high
+func levelName(level string) string {
+	for _, description := range dataaccess.LevelDescriptions {
+		if description.Level.String() == level {
+			return strings.ToLower(description.NameEn)
+		}
+	}
+	return level
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func pollenTypeName(pollenType *PollenTypeRefV2Dto) string {
+	if pollenType.Name != "" {
+		return pollenType.Name
+	}
+	return fmt.Sprintf("Pollen type %v", pollenType.ID)
+}
+
+// escapeICalendarText escapes a TEXT value as described in RFC 5545 section 3.3.11
+func escapeICalendarText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// writeICalendarLine writes a content line ending in CRLF, folded to lines of at most 75 octets without
+// splitting UTF-8 characters
+func writeICalendarLine(writer *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		writer.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space of continuation lines counts towards their length
+		limit = 74
+	}
+	writer.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		format string
+		status int
+	}{
+		{"", formatJSON, 0},
+		{"*/*", formatJSON, 0},
+		{"text/csv", formatCSV, 0},
+		{"text/calendar", formatICalendar, 0},
+		{"application/ndjson", formatNDJSON, 0},
+		{"text/csv;q=0.5, application/json", formatJSON, 0},
+		{"text/*", "", http.StatusNotAcceptable},
+		{"text/plain", "", http.StatusNotAcceptable},
+		{"text/html, text/*;q=0.9", "", http.StatusNotAcceptable},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/v2/pollen", nil)
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+		format, err := negotiateFormat(request)
+		status := 0
+		if err != nil {
+			status = err.(*apiError).Status
+		}
+		if format != test.format || status != test.status {
+			t.Errorf("Accept %q gives format %q and status %d, want %q and %d", test.accept, format, status, test.format, test.status)
+		}
+	}
+}
+
+// flushRecorder counts the flushes of a response
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (recorder *flushRecorder) Flush() {
+	recorder.flushes++
+}
+
+func TestNDJSONFlushesEachLine(t *testing.T) {
+	samples := []*PollenSampleV2Dto{{Date: "2020-05-01"}, {Date: "2020-05-02"}}
+	recorder := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	writeSamplesNDJSON(recorder, samples)
+	if recorder.flushes != len(samples) {
+		t.Errorf("%d flushes of %d samples", recorder.flushes, len(samples))
+	}
+
+	aggregates := []*PollenAggregateV2Dto{{Start: "2020-05-01"}, {Start: "2020-05-08"}}
+	recorder = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	writeAggregatesNDJSON(recorder, aggregates)
+	if recorder.flushes != len(aggregates) {
+		t.Errorf("%d flushes of %d aggregates", recorder.flushes, len(aggregates))
+	}
+}
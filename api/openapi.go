@@ -0,0 +1,85 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPISpec describes every route of newRouter. Update it whenever a route is added or changed.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders openAPISpec in the browser
+//
+//go:embed docs.html
+var docsPage []byte
+
+// Get the OpenAPI document of the API.
+func getOpenAPISpec(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Write(openAPISpec)
+}
+
+// Get the API reference page.
+func getDocs(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.Write(docsPage)
+}
+
+// checkOpenAPISpec compares the routes of router with openAPISpec. It returns a description of every
+// route missing from the document and every operation of the document without a route.
+func checkOpenAPISpec(router *mux.Router) ([]string, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return nil, fmt.Errorf("openapi.json is invalid: %v", err)
+	}
+
+	routed := make(map[string]bool)
+	var problems []string
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		// Routes without methods accept any, which the document can't describe
+		methods, err := route.GetMethods()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s has no methods", path))
+			return nil
+		}
+		for _, method := range methods {
+			operation := strings.ToLower(method) + " " + path
+			routed[operation] = true
+			if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s %s is not documented in openapi.json", method, path))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !routed[method+" "+path] {
+				problems = append(problems, fmt.Sprintf("%s %s is documented in openapi.json but has no route",
+					strings.ToUpper(method), path))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
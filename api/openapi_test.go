@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	router := newRouter(&httpContext{Repo: newTestRepository(), Config: &APIConfig{}})
+	problems, err := checkOpenAPISpec(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
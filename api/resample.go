@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+var errIntervalResample = invalidParameter("interval", errors.New("interval can't be combined with fill or smooth"))
+
+var errSmoothingWithoutSmooth = invalidParameter("smoothing", errors.New("smoothing needs smooth"))
+
+// parseResampleOptions reads the query parameters fill, smooth and smoothing. smoothing defaults to centred.
+// It returns nil when neither fill nor smooth is given. They can't be combined with interval.
+func parseResampleOptions(request *http.Request) (*analysis.ResampleOptions, error) {
+	fill, smooth := request.FormValue("fill"), request.FormValue("smooth")
+	if fill == "" && smooth == "" {
+		if request.FormValue("smoothing") != "" {
+			return nil, errSmoothingWithoutSmooth
+		}
+		return nil, nil
+	}
+	if request.FormValue("interval") != "" {
+		return nil, errIntervalResample
+	}
+	options := &analysis.ResampleOptions{
+		Fill:      analysis.FillMethod(fill),
+		Smoothing: analysis.SmoothCentred,
+	}
+	if value := request.FormValue("smoothing"); value != "" {
+		options.Smoothing = analysis.Smoothing(value)
+	}
+	if smooth != "" {
+		var err error
+		if options.Smooth, err = strconv.Atoi(smooth); err != nil || options.Smooth == 0 {
+			return nil, invalidParameter("smooth", analysis.ErrInvalidSmoothDays)
+		}
+	}
+
+	switch err := options.Validate(); err {
+	case nil:
+		return options, nil
+	case analysis.ErrUnknownFillMethod:
+		return nil, invalidParameter("fill", err)
+	case analysis.ErrUnknownSmoothing:
+		return nil, invalidParameter("smoothing", err)
+	case analysis.ErrInvalidSmoothDays, analysis.ErrEvenCentredSmooth:
+		return nil, invalidParameter("smooth", err)
+	default:
+		return nil, err
+	}
+}
+
+// getPollenResampledV2 writes the pollen data of a query with one sample per day of each location and pollen
+// type when filled, and their moving averages when smoothed. When grouped, JSON is grouped by location and
+// pollen type like getPollenSeriesV2. The range is limited to analysis.MaxResampleDays.
+func (context *httpContext) getPollenResampledV2(responseWriter http.ResponseWriter, request *http.Request,
+	format string, query *dataaccess.PollenQuery, options *analysis.ResampleOptions, grouped bool) {
+	if err := analysis.ValidateResampleRange(query.From, query.To); err != nil {
+		writeError(responseWriter, request, invalidParameter("to", err))
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	locations, pollenTypes, err := context.findPollenSeries(query, refs)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	// Filling and the moving average of the first and last days take the days around the range
+	before, after := options.Margin()
+	samples, err := context.Repo.GetPollenFromRanges(&dataaccess.PollenQuery{
+		From:        query.From.AddDate(0, 0, -before),
+		To:          query.To.AddDate(0, 0, after),
+		PollenTypes: query.PollenTypes,
+		Locations:   query.Locations,
+	})
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	type seriesKey struct {
+		location   int
+		pollenType dataaccess.PollenType
+	}
+	series := make(map[seriesKey][]*dataaccess.PollenSample)
+	for _, sample := range samples {
+		key := seriesKey{sample.Location.Location, sample.PollenType}
+		series[key] = append(series[key], sample)
+	}
+
+	daySamples := []*dataaccess.PollenSample{}
+	results := []*PollenSampleV2Dto{}
+	for _, location := range locations {
+		for _, pollenType := range pollenTypes {
+			days := analysis.ResampleSeries(series[seriesKey{location.Location, pollenType}], pollenType, location,
+				query.From, query.To, options)
+			for _, day := range days {
+				daySamples = append(daySamples, day.Sample)
+				results = append(results, toSeriesDayV2(day, refs))
+			}
+		}
+	}
+	if err = context.addPercentilesOfNormal(request, query, daySamples, results); err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if !grouped || format != formatJSON {
+		context.writeSamples(responseWriter, request, format, results)
+		return
+	}
+	groups := newPollenGroups()
+	for _, result := range results {
+		group := groups.get(result.Location, result.PollenType)
+		group.Samples = append(group.Samples, result)
+	}
+	writeObject(responseWriter, request, groups.locations, nil)
+}
+
+// findPollenSeries returns the locations and pollen types of a query, sorted by id and without duplicates.
+// A query without locations or pollen types has all of them, so those without data get a series too.
+func (context *httpContext) findPollenSeries(query *dataaccess.PollenQuery, refs pollenTypeRefs) ([]*dataaccess.Location, []dataaccess.PollenType, error) {
+	all, err := context.Repo.GetAllLocations()
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[int]*dataaccess.Location)
+	for _, location := range all {
+		byID[location.Location] = location
+	}
+	var locations []*dataaccess.Location
+	if len(query.Locations) == 0 {
+		locations = append(locations, all...)
+	}
+	for _, id := range query.Locations {
+		location, ok := byID[id]
+		if !ok {
+			return nil, nil, sql.ErrNoRows
+		}
+		locations = append(locations, location)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].Location < locations[j].Location
+	})
+	for i := len(locations) - 1; i > 0; i-- {
+		if locations[i].Location == locations[i-1].Location {
+			locations = append(locations[:i], locations[i+1:]...)
+		}
+	}
+
+	pollenTypes := append([]dataaccess.PollenType(nil), query.PollenTypes...)
+	if len(pollenTypes) == 0 {
+		for pollenType := range refs {
+			pollenTypes = append(pollenTypes, pollenType)
+		}
+	}
+	sort.Slice(pollenTypes, func(i, j int) bool {
+		return pollenTypes[i] < pollenTypes[j]
+	})
+	for i := len(pollenTypes) - 1; i > 0; i-- {
+		if pollenTypes[i] == pollenTypes[i-1] {
+			pollenTypes = append(pollenTypes[:i], pollenTypes[i+1:]...)
+		}
+	}
+	return locations, pollenTypes, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+func TestResampleLimits(t *testing.T) {
+	handler := newTestServer(newTestRepository())
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"range too long", "from=2000-01-01&to=2020-01-01&fill=null", "to"},
+		{"smoothed range too long", "from=2000-01-01&to=2020-01-01&smooth=3", "to"},
+		{"interval with fill", "from=2020-05-01&to=2020-05-31&fill=null&interval=week", "interval"},
+		{"interval with smooth", "from=2020-05-01&to=2020-05-31&smooth=3&interval=week", "interval"},
+		{"smoothing without smooth", "from=2020-05-01&to=2020-05-31&smoothing=trailing", "smoothing"},
+		{"even centred smooth", "from=2020-05-01&to=2020-05-31&smooth=4", "smooth"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder, result := serveError(t, handler, http.MethodGet,
+				"/api/v2/pollen?pollentype=0&location=0&"+test.query, "")
+			if recorder.Code != http.StatusBadRequest || result.Field != test.field {
+				t.Errorf("status %d on field %q, want 400 on field %q", recorder.Code, result.Field, test.field)
+			}
+		})
+	}
+}
+
+func TestFillPreviousBeforeRange(t *testing.T) {
+	repo := newTestRepository()
+	count := 20
+	repo.UpsertPollenCount(&dataaccess.PollenSample{
+		Date: time.Date(2020, time.April, 20, 0, 0, 0, 0, time.UTC), Location: dataaccess.Location{Location: 0}, PollenCount: &count,
+	})
+	handler := newTestServer(repo)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v2/pollen?pollentype=0&location=0&from=2020-05-01&to=2020-05-03&fill=previous", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	var samples []*PollenSampleV2Dto
+	if err := json.NewDecoder(recorder.Body).Decode(&samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+	for _, sample := range samples {
+		if sample.PollenCount == nil || *sample.PollenCount != count || sample.Status == nil || *sample.Status != "filled" {
+			t.Errorf("%s: pollen count %v, status %v, want %d filled", sample.Date, sample.PollenCount, sample.Status, count)
+		}
+	}
+}
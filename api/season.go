@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+var errMissingSeasonThreshold = invalidParameter("threshold",
+	errors.New("threshold is required when the pollen type has no levels"))
+
+// Get the pollen season of a year, pollen type and location: its start, peak and end, total load and
+// number of high days. The method and its options are read from the query, see parseSeasonOptions.
+func (context *httpContext) getSeasonV2(responseWriter http.ResponseWriter, request *http.Request) {
+	year, err := parseIntParameter(request, "year")
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	pollenType, err := parseIntParameter(request, "pollentype")
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	locationID, err := parseIntParameter(request, "location")
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	location, err := context.Repo.GetLocation(locationID)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	options, err := parseSeasonOptions(request, refs.thresholds(dataaccess.PollenType(pollenType)))
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	options.Complete = location.Today(context.Clock).Year() > year
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	samples, err := context.Repo.GetPollenFromRange(from, to, dataaccess.PollenType(pollenType), locationID)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	season, err := analysis.DetectSeason(samples, options)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, toSeasonV2(season, year, options,
+		refs.ref(dataaccess.PollenType(pollenType)), location), nil)
+}
+
+// parseSeasonOptions reads the query parameters method, percent, threshold and days. method defaults to
+// threshold, with the moderate threshold of the pollen type and 3 days, or to cumulative with 5 percent
+// when the pollen type has no levels and no threshold is given.
+func parseSeasonOptions(request *http.Request, thresholds dataaccess.Thresholds) (*analysis.SeasonOptions, error) {
+	options := &analysis.SeasonOptions{
+		Method:     analysis.SeasonThreshold,
+		Percent:    analysis.DefaultSeasonPercent,
+		Threshold:  thresholds.Moderate,
+		Days:       analysis.DefaultSeasonDays,
+		Thresholds: thresholds,
+	}
+	if value := request.FormValue("method"); value != "" {
+		options.Method = analysis.SeasonMethod(value)
+	} else if !thresholds.Defined() && request.FormValue("threshold") == "" {
+		options.Method = analysis.SeasonCumulative
+	}
+	var err error
+	if value := request.FormValue("percent"); value != "" {
+		if options.Percent, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, invalidParameter("percent", analysis.ErrInvalidSeasonPercent)
+		}
+	}
+	if value := request.FormValue("threshold"); value != "" {
+		if options.Threshold, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, invalidParameter("threshold", analysis.ErrInvalidSeasonThreshold)
+		}
+	} else if options.Method == analysis.SeasonThreshold && !thresholds.Defined() {
+		return nil, errMissingSeasonThreshold
+	}
+	if value := request.FormValue("days"); value != "" {
+		if options.Days, err = strconv.Atoi(value); err != nil {
+			return nil, invalidParameter("days", analysis.ErrInvalidSeasonDays)
+		}
+	}
+
+	switch err := options.Validate(); err {
+	case nil:
+		return options, nil
+	case analysis.ErrUnknownSeasonMethod:
+		return nil, invalidParameter("method", err)
+	case analysis.ErrInvalidSeasonPercent:
+		return nil, invalidParameter("percent", err)
+	case analysis.ErrInvalidSeasonThreshold:
+		return nil, invalidParameter("threshold", err)
+	case analysis.ErrInvalidSeasonDays:
+		return nil, invalidParameter("days", err)
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/analysis"
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// allIDs selects every pollen type or location in the query parameters pollentype and location
+const allIDs = "all"
+
+// isIDList tells if a query parameter lists several ids or all of them rather than a single id
+func isIDList(value string) bool {
+	return value == allIDs || strings.Contains(value, ",")
+}
+
+// parseIDList reads a query parameter that is an id, a comma-separated list of ids, or all. all is returned
+// as an empty list.
+func parseIDList(request *http.Request, name string) ([]int, error) {
+	value := request.FormValue(name)
+	if value == allIDs {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, invalidParameter(name, fmt.Errorf("%s must be an integer, a comma-separated list of integers or all", name))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parsePollenQuery reads the query parameters from, to, pollentype and location, where pollentype and location
+// may list several ids or be all. Relative dates are resolved in the calendar of the first location listed,
+// or of UTC for all locations.
+func (context *httpContext) parsePollenQuery(request *http.Request) (*dataaccess.PollenQuery, error) {
+	pollenTypes, err := parseIDList(request, "pollentype")
+	if err != nil {
+		return nil, err
+	}
+	locations, err := parseIDList(request, "location")
+	if err != nil {
+		return nil, err
+	}
+
+	var calendar *dataaccess.Location
+	if len(locations) > 0 {
+		if calendar, err = context.Repo.GetLocation(locations[0]); err != nil {
+			return nil, err
+		}
+	}
+	from, err := context.parseDateIn("from", request.FormValue("from"), calendar)
+	if err != nil {
+		return nil, err
+	}
+	to, err := context.parseDateIn("to", request.FormValue("to"), calendar)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &dataaccess.PollenQuery{From: from, To: to, Locations: locations}
+	for _, pollenType := range pollenTypes {
+		query.PollenTypes = append(query.PollenTypes, dataaccess.PollenType(pollenType))
+	}
+	return query, nil
+}
+
+// getPollenSeriesV2 writes the pollen data of several pollen types and locations, found with a single query.
+// JSON is grouped by location and pollen type, the other formats list the data of one after the other.
+// options are nil unless the data is filled or smoothed.
+func (context *httpContext) getPollenSeriesV2(responseWriter http.ResponseWriter, request *http.Request, format string,
+	options *analysis.ResampleOptions) {
+	query, err := context.parsePollenQuery(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if options != nil {
+		context.getPollenResampledV2(responseWriter, request, format, query, options, true)
+		return
+	}
+	if request.FormValue("interval") != "" {
+		context.getPollenAggregatesV2(responseWriter, request, format, query, true)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	samples, err := context.Repo.GetPollenFromRanges(query)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*PollenSampleV2Dto, len(samples))
+	for i, sample := range samples {
+		results[i] = toPollenSampleV2(sample, refs)
+	}
+	if err = context.addPercentilesOfNormal(request, query, samples, results); err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if format != formatJSON {
+		context.writeSamples(responseWriter, request, format, results)
+		return
+	}
+	groups := newPollenGroups()
+	for _, result := range results {
+		group := groups.get(result.Location, result.PollenType)
+		group.Samples = append(group.Samples, result)
+	}
+	writeObject(responseWriter, request, groups.locations, nil)
+}
+
+// pollenGroups groups pollen data sorted by location and pollen type
+type pollenGroups struct {
+	locations []*PollenLocationGroupV2Dto
+}
+
+func newPollenGroups() *pollenGroups {
+	return &pollenGroups{locations: []*PollenLocationGroupV2Dto{}}
+}
+
+// get returns the group of a location and pollen type, adding it after the others if it is new
+func (groups *pollenGroups) get(location *LocationV2Dto, pollenType *PollenTypeRefV2Dto) *PollenTypeGroupV2Dto {
+	last := len(groups.locations) - 1
+	if last < 0 || groups.locations[last].Location.ID != location.ID {
+		groups.locations = append(groups.locations, &PollenLocationGroupV2Dto{Location: location})
+		last++
+	}
+	locationGroup := groups.locations[last]
+	types := locationGroup.PollenTypes
+	if len(types) == 0 || types[len(types)-1].PollenType.ID != pollenType.ID {
+		locationGroup.PollenTypes = append(locationGroup.PollenTypes, &PollenTypeGroupV2Dto{PollenType: pollenType})
+	}
+	return locationGroup.PollenTypes[len(locationGroup.PollenTypes)-1]
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+	"github.com/gorilla/mux"
+)
+
+// registerV2 registers the endpoints of /api/v2 on router. They mirror the paths of v1, but respond with the
+// DTOs of dto_v2.go, and the pollen type and location are always required.
+func registerV2(router *mux.Router, context *httpContext) {
+	router.HandleFunc("/pollentype", context.getPollenTypesV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/levels", context.getLevelsV2).
+		Methods(http.MethodGet)
+
+	// Administration, requires the admin API key
+	router.HandleFunc("/location", context.requireAdmin(context.createLocationV2)).
+		Methods(http.MethodPost)
+	router.HandleFunc("/location/{location}", context.requireAdmin(context.updateLocationV2)).
+		Methods(http.MethodPut)
+	router.HandleFunc("/location/{location}", context.requireAdmin(context.deleteLocation)).
+		Methods(http.MethodDelete)
+	router.HandleFunc("/feedstation", context.requireAdmin(context.getFeedStationsV2)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/feedstation/{location}/{pollentype}", context.requireAdmin(context.putFeedStationV2)).
+		Methods(http.MethodPut)
+	router.HandleFunc("/feedstation/{location}/{pollentype}", context.requireAdmin(context.deleteFeedStation)).
+		Methods(http.MethodDelete)
+
+	router.HandleFunc("/locations", context.getLocationsV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/location/nearest", context.getNearestLocationsV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/location/{location}", context.getLocationV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/location", context.searchLocationV2).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/pollen/{date}", context.getPollenV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/pollen", context.getPollenRangeV2).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/forecast/{date}/history", context.getForecastHistoryV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/forecast/{date}", context.getForecastV2).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/accuracy", context.getAccuracyV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/season", context.getSeasonV2).
+		Methods(http.MethodGet)
+	router.HandleFunc("/climatology", context.getClimatologyV2).
+		Methods(http.MethodGet)
+}
+
+// getPollenTypeRefs looks up the names of all pollen types
+func (context *httpContext) getPollenTypeRefs() (pollenTypeRefs, error) {
+	pollenTypes, err := context.Repo.GetPollenTypes()
+	if err != nil {
+		return nil, err
+	}
+	return toPollenTypeRefs(pollenTypes), nil
+}
+
+// Get all pollen types.
+func (context *httpContext) getPollenTypesV2(responseWriter http.ResponseWriter, request *http.Request) {
+	pollenTypes, err := context.Repo.GetPollenTypes()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*PollenTypeV2Dto, len(pollenTypes))
+	for i, pollenType := range pollenTypes {
+		results[i] = toPollenTypeV2(pollenType)
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// Get the levels in English and Danish, and the counts of each level per pollen type.
+func (context *httpContext) getLevelsV2(responseWriter http.ResponseWriter, request *http.Request) {
+	pollenTypes, err := context.Repo.GetPollenTypes()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, toLevelsV2(pollenTypes), nil)
+}
+
+// Get a location by an id.
+func (context *httpContext) getLocationV2(responseWriter http.ResponseWriter, request *http.Request) {
+	locationID, err := strconv.Atoi(mux.Vars(request)["location"])
+	if err != nil {
+		writeError(responseWriter, request, errInvalidLocationID)
+		return
+	}
+	location, err := context.Repo.GetLocation(locationID)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, toLocationV2(location), nil)
+}
+
+// Get every location, each with the pollen types it has data for and the first and last date of the data.
+func (context *httpContext) getLocationsV2(responseWriter http.ResponseWriter, request *http.Request) {
+	locations, err := context.Repo.GetAllLocations()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	availabilities, err := context.Repo.GetPollenAvailability()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+
+	byLocation := make(map[int][]*PollenAvailabilityV2Dto)
+	for _, availability := range availabilities {
+		byLocation[availability.Location] = append(byLocation[availability.Location], &PollenAvailabilityV2Dto{
+			PollenType: refs.ref(availability.PollenType),
+			FirstDate:  availability.FirstDate.Format(isoDate),
+			LastDate:   availability.LastDate.Format(isoDate),
+		})
+	}
+
+	results := make([]*LocationAvailabilityV2Dto, len(locations))
+	for i, location := range locations {
+		results[i] = &LocationAvailabilityV2Dto{
+			LocationV2Dto: *toLocationV2(location),
+			PollenTypes:   byLocation[location.Location],
+		}
+		if results[i].PollenTypes == nil {
+			results[i].PollenTypes = []*PollenAvailabilityV2Dto{}
+		}
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// Search locations by country and/or city, best match first. The results are paged with offset and limit.
+func (context *httpContext) searchLocationV2(responseWriter http.ResponseWriter, request *http.Request) {
+	query, err := parseLocationQuery(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	result, err := context.Repo.SearchLocation(query)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, &LocationSearchV2Dto{
+		Locations: toLocationsV2(result.Locations),
+		Total:     result.Total,
+		Offset:    query.Offset,
+		Limit:     query.Limit,
+	}, nil)
+}
+
+// Get the locations nearest to a point given by lat and lon, nearest first, with their distance in kilometers.
+func (context *httpContext) getNearestLocationsV2(responseWriter http.ResponseWriter, request *http.Request) {
+	nearestLocations, err := context.findNearestLocations(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*LocationDistanceV2Dto, len(nearestLocations))
+	for i, nearest := range nearestLocations {
+		results[i] = &LocationDistanceV2Dto{
+			Location:   toLocationV2(nearest.Location),
+			DistanceKm: nearest.DistanceKm,
+		}
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// Get the pollen count and predicted pollen count for a date, pollen type and location. The format follows
+// the query parameter format or the Accept header, see negotiateFormat.
+func (context *httpContext) getPollenV2(responseWriter http.ResponseWriter, request *http.Request) {
+	format, err := negotiateFormat(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	date, pollenType, location, err := context.parseForecastRequest(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	sample, err := context.Repo.GetPollen(date, pollenType, location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	result := toPollenSampleV2(sample, refs)
+	err = context.addPercentilesOfNormal(request,
+		&dataaccess.PollenQuery{PollenTypes: []dataaccess.PollenType{pollenType}, Locations: []int{location}},
+		[]*dataaccess.PollenSample{sample}, []*PollenSampleV2Dto{result})
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if format == formatJSON {
+		writeObject(responseWriter, request, result, nil)
+		return
+	}
+	context.writeSamples(responseWriter, request, format, []*PollenSampleV2Dto{result})
+}
+
+// Get the pollen counts and predicted pollen counts for a range of dates, a pollen type and a location. The
+// format follows the query parameter format or the Accept header, see negotiateFormat. With the query
+// parameter interval, the data is summed up per day, week, month or season instead. pollentype and location
+// may list several ids or be all, see getPollenSeriesV2. With the query parameters fill or smooth, every day
+// of the range is included or the counts are smoothed, see getPollenResampledV2.
+func (context *httpContext) getPollenRangeV2(responseWriter http.ResponseWriter, request *http.Request) {
+	format, err := negotiateFormat(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	options, err := parseResampleOptions(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if isIDList(request.FormValue("pollentype")) || isIDList(request.FormValue("location")) {
+		context.getPollenSeriesV2(responseWriter, request, format, options)
+		return
+	}
+	query, err := context.parsePollenRange(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	if options != nil {
+		context.getPollenResampledV2(responseWriter, request, format, &dataaccess.PollenQuery{
+			From:        query.From,
+			To:          query.To,
+			PollenTypes: []dataaccess.PollenType{query.PollenType},
+			Locations:   []int{query.Location},
+		}, options, false)
+		return
+	}
+	if request.FormValue("interval") != "" {
+		context.getPollenAggregatesV2(responseWriter, request, format, &dataaccess.PollenQuery{
+			From:        query.From,
+			To:          query.To,
+			PollenTypes: []dataaccess.PollenType{query.PollenType},
+			Locations:   []int{query.Location},
+		}, false)
+		return
+	}
+	samples, err := context.Repo.GetPollenFromRange(query.From, query.To, query.PollenType, query.Location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*PollenSampleV2Dto, len(samples))
+	for i, sample := range samples {
+		results[i] = toPollenSampleV2(sample, refs)
+	}
+	err = context.addPercentilesOfNormal(request, &dataaccess.PollenQuery{
+		PollenTypes: []dataaccess.PollenType{query.PollenType},
+		Locations:   []int{query.Location},
+	}, samples, results)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	context.writeSamples(responseWriter, request, format, results)
+}
+
+// getPollenAggregatesV2 writes the pollen data of a query summed up per the query parameter interval. When
+// grouped, JSON is grouped by location and pollen type like getPollenSeriesV2.
+func (context *httpContext) getPollenAggregatesV2(responseWriter http.ResponseWriter, request *http.Request,
+	format string, query *dataaccess.PollenQuery, grouped bool) {
+	interval := dataaccess.Interval(request.FormValue("interval"))
+	if err := dataaccess.ValidateInterval(interval); err != nil {
+		writeError(responseWriter, request, invalidParameter("interval", err))
+		return
+	}
+	if format == formatICalendar {
+		writeError(responseWriter, request, errIntervalFormat)
+		return
+	}
+	aggregates, err := context.Repo.GetPollenAggregates(query, interval)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*PollenAggregateV2Dto, len(aggregates))
+	for i, aggregate := range aggregates {
+		results[i] = toPollenAggregateV2(aggregate, refs)
+	}
+	if !grouped || format != formatJSON {
+		context.writeAggregates(responseWriter, request, format, results)
+		return
+	}
+	groups := newPollenGroups()
+	for _, result := range results {
+		group := groups.get(result.Location, result.PollenType)
+		group.Aggregates = append(group.Aggregates, result)
+	}
+	writeObject(responseWriter, request, groups.locations, nil)
+}
+
+// Get the latest forecast for a date, pollen type and location, or the one published on the day issued.
+func (context *httpContext) getForecastV2(responseWriter http.ResponseWriter, request *http.Request) {
+	forecast, err := context.findForecast(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results, err := context.toPollenForecastsV2([]*dataaccess.PollenForecast{forecast})
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, results[0], nil)
+}
+
+// Get every forecast issued for a date, pollen type and location, oldest first.
+func (context *httpContext) getForecastHistoryV2(responseWriter http.ResponseWriter, request *http.Request) {
+	date, pollenType, location, err := context.parseForecastRequest(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	forecasts, err := context.Repo.GetPollenForecasts(date, pollenType, location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results, err := context.toPollenForecastsV2(forecasts)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// toPollenForecastsV2 maps forecasts, looking up their locations and pollen types
+func (context *httpContext) toPollenForecastsV2(forecasts []*dataaccess.PollenForecast) ([]*PollenForecastV2Dto, error) {
+	refs, err := context.getPollenTypeRefs()
+	if err != nil {
+		return nil, err
+	}
+	locations := make(map[int]*dataaccess.Location)
+	results := make([]*PollenForecastV2Dto, len(forecasts))
+	for i, forecast := range forecasts {
+		location, ok := locations[forecast.Location]
+		if !ok {
+			location, err = context.Repo.GetLocation(forecast.Location)
+			if err != nil {
+				return nil, err
+			}
+			locations[forecast.Location] = location
+		}
+		results[i] = toPollenForecastV2(forecast, location, refs)
+	}
+	return results, nil
+}
+
+// Create a location from the JSON body. The location is given the next free id.
+func (context *httpContext) createLocationV2(responseWriter http.ResponseWriter, request *http.Request) {
+	location, err := readLocationV2(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	err = context.Repo.CreateLocation(location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(responseWriter).Encode(toLocationV2(location))
+}
+
+// Update a location from the JSON body.
+func (context *httpContext) updateLocationV2(responseWriter http.ResponseWriter, request *http.Request) {
+	locationID, err := strconv.Atoi(mux.Vars(request)["location"])
+	if err != nil {
+		writeError(responseWriter, request, errInvalidLocationID)
+		return
+	}
+	location, err := readLocationV2(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	location.Location = locationID
+
+	err = context.Repo.UpdateLocation(location)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	writeObject(responseWriter, request, toLocationV2(location), nil)
+}
+
+// readLocationV2 reads a LocationInputV2Dto from the JSON body of a request. TimeZone defaults to UTC.
+func readLocationV2(request *http.Request) (*dataaccess.Location, error) {
+	input := &LocationInputV2Dto{}
+	err := json.NewDecoder(request.Body).Decode(input)
+	if err != nil {
+		return nil, invalidParameter("", err)
+	}
+	location := input.toLocation()
+	return location, camelCaseField(validateLocation(location))
+}
+
+// List all mappings from location and pollen type to astma-allergi.dk feed stations.
+func (context *httpContext) getFeedStationsV2(responseWriter http.ResponseWriter, request *http.Request) {
+	stations, err := context.Repo.GetFeedStations()
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	results := make([]*FeedStationV2Dto, len(stations))
+	for i, station := range stations {
+		results[i] = toFeedStationV2(station)
+	}
+	writeObject(responseWriter, request, results, nil)
+}
+
+// Create or update the feed station of a location and pollen type from the JSON body.
+func (context *httpContext) putFeedStationV2(responseWriter http.ResponseWriter, request *http.Request) {
+	location, pollenType, err := parseFeedStationKey(request)
+	if err != nil {
+		writeError(responseWriter, request, err)
+		return
+	}
+	input := &FeedStationInputV2Dto{}
+	err = json.NewDecoder(request.Body).Decode(input)
+	if err != nil {
+		writeError(responseWriter, request, invalidParameter("", err))
+		return
+	}
+	station := &dataaccess.FeedStation{
+		Location:   location,
+		PollenType: pollenType,
+		StationID:  input.StationID,
+		TypeID:     input.TypeID,
+	}
+	err = context.saveFeedStation(station)
+	if err != nil {
+		writeError(responseWriter, request, camelCaseField(toAPIError(err, getRequestID(request))))
+		return
+	}
+	writeObject(responseWriter, request, toFeedStationV2(station), nil)
+}
+
+// camelCaseField renames the field of a validation error from the name of the dataaccess struct field,
+// e.g. "TimeZone", to the name of the v2 DTO field, "timeZone"
+func camelCaseField(err error) error {
+	result, ok := err.(*apiError)
+	if !ok || result.Field == "" {
+		return err
+	}
+	first, size := utf8.DecodeRuneInString(result.Field)
+	renamed := *result
+	renamed.Field = string(unicode.ToLower(first)) + result.Field[size:]
+	if renamed.Field == "stationID" || renamed.Field == "typeID" {
+		renamed.Field = renamed.Field[:len(renamed.Field)-2] + "Id"
+	}
+	return &renamed
+}
+
+// v1DeprecatedAt is when v2 was released and v1 frozen
+var v1DeprecatedAt = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// v1Sunset is when v1 will be removed
+var v1Sunset = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+
+// deprecationMiddleware marks responses of v1 as deprecated in favor of v2, following RFC 9745 and RFC 8594
+func deprecationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(v1DeprecatedAt.Unix(), 10))
+		w.Header().Set("Sunset", v1Sunset.Format(http.TimeFormat))
+		w.Header().Set("Link", `</api/v2>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+// Package admin implements the administration commands shared by the API and the pollen collector.
+// Both executeables run a command instead of their normal work when one is given as arguments.
+package admin
+
+import (
+	"fmt"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// Usage describes the available commands
+const Usage = `Commands:
+  migrate status                         List all migrations and whether they are applied
+  migrate up [version]                   Apply pending migrations up to version, default the latest
+  migrate down [version]                 Revert migrations down to version, default the previous one.
+                                         Migration 1 can't be reverted
+  location list                          List all locations
+  location create <country> <city> <time zone>
+                                         Create a location with the next free id. The time zone is
+                                         an IANA name like Europe/Copenhagen
+  location update <id> <country> <city> <time zone>
+                                         Change the country, city and time zone of a location
+  location position <id> <latitude> <longitude> [elevation]
+                                         Set the coordinates in degrees and the elevation in meters of a location
+  location delete <id> [cascade]         Delete a location, with cascade also its pollen data
+  pollentype list                        List all pollen types
+  pollentype set <id> <code> <name> <danish name> <latin name> <prediction name> <feed type id>
+                                         Create or update a pollen type. Use "" for a pollen type that
+                                         isn't predicted, and 0 for one that isn't in the feed
+  pollentype thresholds <id> <moderate> <high> <very high>
+                                         Set the lowest counts of the moderate, high and very high
+                                         levels of a pollen type. Use 0 0 0 for no levels
+  feedstation list                       List the astma-allergi.dk stations used per location and pollen type
+  feedstation set <location> <pollen type> <station id> [type id]
+                                         Map a location and pollen type to a feed station. The type id
+                                         defaults to the feed type id of the pollen type
+  feedstation delete <location> <pollen type>
+                                         Stop collecting a location and pollen type`
+
+// Run executes the command given in args, e.g. ["migrate", "up"]
+func Run(repo *dataaccess.PollenRepository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No command given\n%s", Usage)
+	}
+	// migrate has to work on any schema version, everything else needs the latest
+	switch args[0] {
+	case "migrate":
+		return migrate(repo, args[1:])
+	case "location":
+		repo.InitDb()
+		return location(repo, args[1:])
+	case "pollentype":
+		repo.InitDb()
+		return pollenType(repo, args[1:])
+	case "feedstation":
+		repo.InitDb()
+		return feedStation(repo, args[1:])
+	default:
+		return fmt.Errorf("Unknown command: %s\n%s", args[0], Usage)
+	}
+}
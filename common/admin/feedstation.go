@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// feedStation runs "feedstation list/set/delete"
+func feedStation(repo *dataaccess.PollenRepository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("feedstation needs one of list, set or delete\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "list":
+		stations, err := repo.GetFeedStations()
+		if err != nil {
+			return err
+		}
+		for _, station := range stations {
+			fmt.Printf("location %4v  pollen type %4v  station_id %4v  type_id %4v\n",
+				station.Location, station.PollenType, station.StationID, station.TypeID)
+		}
+	case "set":
+		if len(args) < 4 || len(args) > 5 {
+			return fmt.Errorf("Usage: feedstation set <location> <pollen type> <station id> [type id]")
+		}
+		numbers, err := parseInts(args[1:])
+		if err != nil {
+			return err
+		}
+		station := &dataaccess.FeedStation{
+			Location:   numbers[0],
+			PollenType: dataaccess.PollenType(numbers[1]),
+			StationID:  numbers[2],
+		}
+		if len(numbers) == 4 {
+			station.TypeID = numbers[3]
+		}
+		err = dataaccess.CompleteFeedStation(repo, station)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Unknown location %v or pollen type %v", station.Location, station.PollenType)
+		}
+		if err != nil {
+			return err
+		}
+		if err := repo.UpsertFeedStation(station); err != nil {
+			return err
+		}
+		fmt.Printf("Saved feed station with station_id %v and type_id %v\n", station.StationID, station.TypeID)
+	case "delete":
+		if len(args) != 3 {
+			return fmt.Errorf("Usage: feedstation delete <location> <pollen type>")
+		}
+		numbers, err := parseInts(args[1:])
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteFeedStation(numbers[0], dataaccess.PollenType(numbers[1])); err != nil {
+			return err
+		}
+		fmt.Println("Deleted feed station")
+	default:
+		return fmt.Errorf("Unknown feedstation command: %s\n%s", args[0], Usage)
+	}
+	return nil
+}
+
+// parseInts parses all args as integers
+func parseInts(args []string) ([]int, error) {
+	numbers := make([]int, len(args))
+	for i, arg := range args {
+		number, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number: %s", arg)
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// location runs "location list/create/update/position/delete"
+func location(repo *dataaccess.PollenRepository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("location needs one of list, create, update, position or delete\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listLocations(repo)
+	case "create":
+		if len(args) != 4 {
+			return fmt.Errorf("Usage: location create <country> <city> <time zone>")
+		}
+		location := &dataaccess.Location{Country: args[1], City: args[2], TimeZone: args[3]}
+		if err := dataaccess.ValidateTimeZone(location.TimeZone); err != nil {
+			return err
+		}
+		if err := repo.CreateLocation(location); err != nil {
+			return err
+		}
+		fmt.Printf("Created location %v\n", location.Location)
+	case "update":
+		if len(args) != 5 {
+			return fmt.Errorf("Usage: location update <id> <country> <city> <time zone>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid location id: %s", args[1])
+		}
+		if err := dataaccess.ValidateTimeZone(args[4]); err != nil {
+			return err
+		}
+		// Keep the coordinates, which are changed with "location position"
+		location, err := repo.GetLocation(id)
+		if err != nil {
+			return err
+		}
+		location.Country, location.City, location.TimeZone = args[2], args[3], args[4]
+		if err := repo.UpdateLocation(location); err != nil {
+			return err
+		}
+		fmt.Printf("Updated location %v\n", id)
+	case "position":
+		if len(args) < 4 || len(args) > 5 {
+			return fmt.Errorf("Usage: location position <id> <latitude> <longitude> [elevation]")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid location id: %s", args[1])
+		}
+		coordinates, err := parseFloats(args[2:])
+		if err != nil {
+			return err
+		}
+		if err := dataaccess.ValidateCoordinates(coordinates[0], coordinates[1]); err != nil {
+			return err
+		}
+		location, err := repo.GetLocation(id)
+		if err != nil {
+			return err
+		}
+		location.Latitude, location.Longitude, location.Elevation = &coordinates[0], &coordinates[1], nil
+		if len(coordinates) == 3 {
+			location.Elevation = &coordinates[2]
+		}
+		if err := repo.UpdateLocation(location); err != nil {
+			return err
+		}
+		fmt.Printf("Updated position of location %v\n", id)
+	case "delete":
+		if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "cascade") {
+			return fmt.Errorf("Usage: location delete <id> [cascade]")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid location id: %s", args[1])
+		}
+		err = repo.DeleteLocation(id, len(args) == 3)
+		if err == dataaccess.ErrLocationInUse {
+			return fmt.Errorf("Location %v has pollen data, add cascade to delete it as well", id)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted location %v\n", id)
+	default:
+		return fmt.Errorf("Unknown location command: %s\n%s", args[0], Usage)
+	}
+	return nil
+}
+
+func listLocations(repo *dataaccess.PollenRepository) error {
+	locations, err := repo.GetAllLocations()
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		fmt.Printf("%4v  %-20s %-20s %-20s %s\n", location.Location, location.Country, location.City,
+			location.TimeZone, formatPosition(location))
+	}
+	return nil
+}
+
+// formatPosition formats the coordinates and elevation of a location, or nothing if they are unknown
+func formatPosition(location *dataaccess.Location) string {
+	if location.Latitude == nil || location.Longitude == nil {
+		return ""
+	}
+	position := fmt.Sprintf("%.4f, %.4f", *location.Latitude, *location.Longitude)
+	if location.Elevation != nil {
+		position += fmt.Sprintf(" (%.0f m)", *location.Elevation)
+	}
+	return position
+}
+
+// parseFloats parses every argument as a number
+func parseFloats(args []string) ([]float64, error) {
+	results := make([]float64, len(args))
+	for i, arg := range args {
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number: %s", arg)
+		}
+		results[i] = value
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// migrate runs "migrate up/down/status"
+func migrate(repo *dataaccess.PollenRepository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate needs one of up, down or status\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "status":
+		return migrateStatus(repo)
+	case "up":
+		target, err := parseVersion(args[1:], 0)
+		if err != nil {
+			return err
+		}
+		if err := repo.MigrateUp(target); err != nil {
+			return err
+		}
+	case "down":
+		version, err := repo.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		target, err := parseVersion(args[1:], version-1)
+		if err != nil {
+			return err
+		}
+		if target < 1 {
+			return fmt.Errorf("Migration 1 can't be reverted, as it adopts the tables of databases older than migrations")
+		}
+		if err := repo.MigrateDown(target); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown migrate command: %s\n%s", args[0], Usage)
+	}
+	return migrateStatus(repo)
+}
+
+// parseVersion reads an optional version argument
+func parseVersion(args []string, defaultVersion int) (int, error) {
+	if len(args) == 0 {
+		return defaultVersion, nil
+	}
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("Invalid version: %s", args[0])
+	}
+	return version, nil
+}
+
+func migrateStatus(repo *dataaccess.PollenRepository) error {
+	version, err := repo.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	statuses, err := repo.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version %v, latest known version %v\n", version, dataaccess.LatestSchemaVersion())
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4v  %-50s %s\n", status.Version, status.Description, applied)
+	}
+	if version > dataaccess.LatestSchemaVersion() {
+		fmt.Println("The database has migrations unknown to this version and must be upgraded first")
+	}
+	return nil
+}
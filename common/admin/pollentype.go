@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// pollenType runs "pollentype list/set/thresholds"
+func pollenType(repo *dataaccess.PollenRepository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("pollentype needs one of list, set or thresholds\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "list":
+		pollenTypes, err := repo.GetPollenTypes()
+		if err != nil {
+			return err
+		}
+		for _, pollenType := range pollenTypes {
+			fmt.Printf("%4v  %-10s %-12s %-12s %-16s prediction: %-10q feed type: %-4v thresholds: %v\n",
+				pollenType.PollenType, pollenType.Code, pollenType.NameEn, pollenType.NameDa,
+				pollenType.LatinName, pollenType.PredictionName, pollenType.FeedTypeID,
+				formatThresholds(pollenType.Thresholds))
+		}
+	case "set":
+		if len(args) != 8 {
+			return fmt.Errorf("Usage: pollentype set <id> <code> <name> <danish name> <latin name> <prediction name> <feed type id>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid pollen type id: %s", args[1])
+		}
+		feedTypeID, err := strconv.Atoi(args[7])
+		if err != nil {
+			return fmt.Errorf("Invalid feed type id: %s", args[7])
+		}
+		// Keep the thresholds of an existing pollen type
+		existing, err := findPollenType(repo, dataaccess.PollenType(id))
+		if err != nil {
+			return err
+		}
+		var thresholds dataaccess.Thresholds
+		if existing != nil {
+			thresholds = existing.Thresholds
+		}
+		err = repo.UpsertPollenType(&dataaccess.PollenTypeDefinition{
+			PollenType:     dataaccess.PollenType(id),
+			Code:           args[2],
+			NameEn:         args[3],
+			NameDa:         args[4],
+			LatinName:      args[5],
+			PredictionName: args[6],
+			FeedTypeID:     feedTypeID,
+			Thresholds:     thresholds,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved pollen type %v\n", id)
+	case "thresholds":
+		if len(args) != 5 {
+			return fmt.Errorf("Usage: pollentype thresholds <id> <moderate> <high> <very high>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid pollen type id: %s", args[1])
+		}
+		values, err := parseFloats(args[2:])
+		if err != nil {
+			return err
+		}
+		thresholds := dataaccess.Thresholds{Moderate: values[0], High: values[1], VeryHigh: values[2]}
+		if err := thresholds.Validate(); err != nil {
+			return err
+		}
+		existing, err := findPollenType(repo, dataaccess.PollenType(id))
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("Unknown pollen type: %v", id)
+		}
+		existing.Thresholds = thresholds
+		if err := repo.UpsertPollenType(existing); err != nil {
+			return err
+		}
+		fmt.Printf("Saved thresholds of pollen type %v\n", id)
+	default:
+		return fmt.Errorf("Unknown pollentype command: %s\n%s", args[0], Usage)
+	}
+	return nil
+}
+
+// findPollenType returns the pollen type with an id, or nil if there is none
+func findPollenType(repo *dataaccess.PollenRepository, id dataaccess.PollenType) (*dataaccess.PollenTypeDefinition, error) {
+	pollenTypes, err := repo.GetPollenTypes()
+	if err != nil {
+		return nil, err
+	}
+	for _, pollenType := range pollenTypes {
+		if pollenType.PollenType == id {
+			return pollenType, nil
+		}
+	}
+	return nil, nil
+}
+
+// formatThresholds shows the thresholds of a pollen type, or "none"
+func formatThresholds(thresholds dataaccess.Thresholds) string {
+	if !thresholds.Defined() {
+		return "none"
+	}
+	return fmt.Sprintf("%v/%v/%v", thresholds.Moderate, thresholds.High, thresholds.VeryHigh)
+}
@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// Metrics compares predicted pollen counts to measured ones. The error measures are nil when there
+// is nothing to compare.
+type Metrics struct {
+	// Count is the number of days with both a measured and a predicted count
+	Count int
+	// MAE is the mean absolute error, RMSE the root mean squared error and Bias the mean of
+	// predicted minus measured
+	MAE  *float64
+	RMSE *float64
+	Bias *float64
+	// HitRate is the share of days where the predicted level is the measured level. It and Categories
+	// are left out if the pollen type has no levels.
+	HitRate *float64
+	// Categories has the hit rate of the days of each measured level
+	Categories []*CategoryHitRate
+	// Baseline is the persistence forecast, predicting the count measured the day before, on the days
+	// where it exists as well
+	Baseline *BaselineMetrics
+}
+
+// CategoryHitRate is the hit rate of the days measured at a level
+type CategoryHitRate struct {
+	Level   dataaccess.Level
+	Count   int
+	Hits    int
+	HitRate *float64
+}
+
+// BaselineMetrics compares the prediction to the persistence forecast
+type BaselineMetrics struct {
+	// Count is the number of days with a measured count, a predicted count and a measured count the day before
+	Count int
+	MAE   *float64
+	RMSE  *float64
+	// Skill is the mean squared error skill score 1 - MSE(prediction) / MSE(baseline). Above 0 the prediction
+	// beats the baseline, and 1 is perfect. It is nil if the baseline is never wrong.
+	Skill *float64
+}
+
+// MonthMetrics are the metrics of a calendar month
+type MonthMetrics struct {
+	// Month is the first day of the month
+	Month time.Time
+	Metrics
+}
+
+// Accuracy is the accuracy over a period, and broken down per month
+type Accuracy struct {
+	Metrics
+	Months []*MonthMetrics
+}
+
+// accuracyDay is a measured count with its prediction and baseline
+type accuracyDay struct {
+	date      time.Time
+	measured  float64
+	predicted float64
+	baseline  *float64
+}
+
+// CalculateAccuracy compares predictions to measured counts of the samples dated from from to to. The
+// samples may include the day before from, whose measured count is used as the baseline of from.
+func CalculateAccuracy(samples []*dataaccess.PollenSample, from time.Time, to time.Time, thresholds dataaccess.Thresholds) *Accuracy {
+	measured := make(map[time.Time]float64)
+	for _, sample := range samples {
+		if sample.PollenCount != nil {
+			measured[sample.Date] = float64(*sample.PollenCount)
+		}
+	}
+
+	var days []*accuracyDay
+	for _, sample := range samples {
+		if sample.Date.Before(from) || sample.Date.After(to) ||
+			sample.PollenCount == nil || sample.PredictedPollenCount == nil {
+			continue
+		}
+		day := &accuracyDay{
+			date:      sample.Date,
+			measured:  float64(*sample.PollenCount),
+			predicted: float64(*sample.PredictedPollenCount),
+		}
+		if yesterday, ok := measured[sample.Date.AddDate(0, 0, -1)]; ok {
+			day.baseline = &yesterday
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].date.Before(days[j].date)
+	})
+
+	accuracy := &Accuracy{Metrics: *calculateMetrics(days, thresholds), Months: []*MonthMetrics{}}
+	for start := 0; start < len(days); {
+		month := time.Date(days[start].date.Year(), days[start].date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end := start
+		for end < len(days) && days[end].date.Year() == month.Year() && days[end].date.Month() == month.Month() {
+			end++
+		}
+		accuracy.Months = append(accuracy.Months, &MonthMetrics{
+			Month:   month,
+			Metrics: *calculateMetrics(days[start:end], thresholds),
+		})
+		start = end
+	}
+	return accuracy
+}
+
+func calculateMetrics(days []*accuracyDay, thresholds dataaccess.Thresholds) *Metrics {
+	metrics := &Metrics{Count: len(days), Baseline: &BaselineMetrics{}}
+	categories := make(map[dataaccess.Level]*CategoryHitRate)
+	if thresholds.Defined() {
+		for _, level := range dataaccess.Levels {
+			categories[level] = &CategoryHitRate{Level: level}
+			metrics.Categories = append(metrics.Categories, categories[level])
+		}
+	}
+
+	var absoluteSum, squaredSum, errorSum float64
+	var hits int
+	var baselineAbsoluteSum, baselineSquaredSum, comparedSquaredSum float64
+	for _, day := range days {
+		difference := day.predicted - day.measured
+		absoluteSum += math.Abs(difference)
+		squaredSum += difference * difference
+		errorSum += difference
+
+		if category, ok := categories[thresholds.Level(day.measured)]; ok {
+			category.Count++
+			if thresholds.Level(day.predicted) == category.Level {
+				category.Hits++
+				hits++
+			}
+		}
+
+		if day.baseline != nil {
+			baselineDifference := *day.baseline - day.measured
+			metrics.Baseline.Count++
+			baselineAbsoluteSum += math.Abs(baselineDifference)
+			baselineSquaredSum += baselineDifference * baselineDifference
+			comparedSquaredSum += difference * difference
+		}
+	}
+
+	if len(days) > 0 {
+		count := float64(len(days))
+		metrics.MAE = float(absoluteSum / count)
+		metrics.RMSE = float(math.Sqrt(squaredSum / count))
+		metrics.Bias = float(errorSum / count)
+		if thresholds.Defined() {
+			metrics.HitRate = float(float64(hits) / count)
+		}
+	}
+	for _, category := range metrics.Categories {
+		if category.Count > 0 {
+			category.HitRate = float(float64(category.Hits) / float64(category.Count))
+		}
+	}
+	if metrics.Baseline.Count > 0 {
+		count := float64(metrics.Baseline.Count)
+		metrics.Baseline.MAE = float(baselineAbsoluteSum / count)
+		metrics.Baseline.RMSE = float(math.Sqrt(baselineSquaredSum / count))
+		if baselineSquaredSum > 0 {
+			metrics.Baseline.Skill = float(1 - comparedSquaredSum/baselineSquaredSum)
+		}
+	}
+	return metrics
+}
+
+// float returns a pointer to value
+func float(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// DefaultClimatologyWindow is the number of days before and after a day of year whose counts are pooled with it
+const DefaultClimatologyWindow = 3
+
+// ClimatologyDay is the distribution of the measured counts of a day of year in the reference period.
+// P10 to P90 are percentiles.
+type ClimatologyDay struct {
+	Month  time.Month
+	Day    int
+	Count  int
+	Mean   float64
+	Median float64
+	P10    float64
+	P25    float64
+	P75    float64
+	P90    float64
+}
+
+// Climatology is the normal pollen count of every day of year, from the measured counts of a reference period
+type Climatology struct {
+	Window int
+	Days   []*ClimatologyDay
+	// values has the sorted counts of each day of year, by dayOfYear
+	values [][]float64
+}
+
+// daysInClimatologyYear is the number of days of year. February 29 is always counted, so every other date has
+// the same day of year in leap years and other years.
+const daysInClimatologyYear = 366
+
+// dayOfYear returns the day of year of a date, 0 to 365, as if every year was a leap year
+func dayOfYear(date time.Time) int {
+	return time.Date(2000, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).YearDay() - 1
+}
+
+// CalculateClimatology pools the measured counts of the samples by day of year. Each day includes the counts
+// of the window days before and after it, which wraps around new year.
+func CalculateClimatology(samples []*dataaccess.PollenSample, window int) *Climatology {
+	counts := make([][]float64, daysInClimatologyYear)
+	for _, sample := range samples {
+		if sample.PollenCount != nil {
+			day := dayOfYear(sample.Date)
+			counts[day] = append(counts[day], float64(*sample.PollenCount))
+		}
+	}
+
+	climatology := &Climatology{Window: window, values: make([][]float64, daysInClimatologyYear)}
+	for day := range counts {
+		var values []float64
+		for offset := -window; offset <= window; offset++ {
+			values = append(values, counts[(day+offset+daysInClimatologyYear)%daysInClimatologyYear]...)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		climatology.values[day] = values
+
+		var sum float64
+		for _, value := range values {
+			sum += value
+		}
+		date := time.Date(2000, time.January, day+1, 0, 0, 0, 0, time.UTC)
+		climatology.Days = append(climatology.Days, &ClimatologyDay{
+			Month:  date.Month(),
+			Day:    date.Day(),
+			Count:  len(values),
+			Mean:   sum / float64(len(values)),
+			Median: percentile(values, 50),
+			P10:    percentile(values, 10),
+			P25:    percentile(values, 25),
+			P75:    percentile(values, 75),
+			P90:    percentile(values, 90),
+		})
+	}
+	return climatology
+}
+
+// PercentileOfNormal returns the percentile rank, 0 to 100, of a count among the counts of the day of year of
+// date. Counts equal to it count as half below. It is nil if the day of year has no counts.
+func (climatology *Climatology) PercentileOfNormal(date time.Time, count float64) *float64 {
+	values := climatology.values[dayOfYear(date)]
+	if len(values) == 0 {
+		return nil
+	}
+	below := sort.SearchFloat64s(values, count)
+	equal := sort.SearchFloat64s(values, math.Nextafter(count, math.Inf(1))) - below
+	rank := 100 * (float64(below) + float64(equal)/2) / float64(len(values))
+	return &rank
+}
+
+// percentile interpolates the p-th percentile of sorted values linearly between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	position := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(position))
+	if lower+1 >= len(values) {
+		return values[len(values)-1]
+	}
+	return values[lower] + (position-float64(lower))*(values[lower+1]-values[lower])
+}
@@ -0,0 +1,247 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// FillMethod decides what count a day without a measured count gets
+type FillMethod string
+
+const (
+	// FillNull leaves days without a measured count without a count
+	FillNull FillMethod = "null"
+	// FillPrevious gives days without a measured count the last measured count before them
+	FillPrevious FillMethod = "previous"
+	// FillInterpolate interpolates days without a measured count linearly between the measured counts
+	// before and after them, rounded to whole counts
+	FillInterpolate FillMethod = "interpolate"
+)
+
+// Smoothing decides which days the moving average of a day is taken over
+type Smoothing string
+
+const (
+	// SmoothCentred averages the day with the same number of days before and after it
+	SmoothCentred Smoothing = "centred"
+	// SmoothTrailing averages the day with the days before it
+	SmoothTrailing Smoothing = "trailing"
+)
+
+// MaxSmoothDays is the longest moving average
+const MaxSmoothDays = 31
+
+// MaxResampleDays is the longest range that can be filled or smoothed, as every day of it is returned
+const MaxResampleDays = 3660
+
+// FillLookbackDays is how far before and after a range FillPrevious and FillInterpolate look for the
+// measured counts they fill the days of the range from
+const FillLookbackDays = 31
+
+// Errors of invalid ResampleOptions
+var (
+	ErrUnknownFillMethod = errors.New("fill must be null, previous or interpolate")
+	ErrUnknownSmoothing  = errors.New("smoothing must be centred or trailing")
+	ErrInvalidSmoothDays = errors.New("smooth must be from 1 to 31")
+	ErrEvenCentredSmooth = errors.New("smooth must be odd when centred")
+	ErrResampleRange     = errors.New("the range can be at most 3660 days when filled or smoothed")
+)
+
+// DayStatus tells where the count of a day of a resampled series comes from
+type DayStatus string
+
+const (
+	// DayMeasured has a measured count
+	DayMeasured DayStatus = "measured"
+	// DayMissing has no count
+	DayMissing DayStatus = "missing"
+	// DayFilled has a count from the fill method
+	DayFilled DayStatus = "filled"
+)
+
+// ResampleOptions configure ResampleSeries
+type ResampleOptions struct {
+	// Fill adds the days without samples when set. Without it only the days with samples are kept.
+	Fill FillMethod
+	// Smooth is the number of days of the moving average of the counts, 0 for none
+	Smooth    int
+	Smoothing Smoothing
+}
+
+// Validate checks the fill method and the moving average
+func (options *ResampleOptions) Validate() error {
+	switch options.Fill {
+	case "", FillNull, FillPrevious, FillInterpolate:
+	default:
+		return ErrUnknownFillMethod
+	}
+	if options.Smooth == 0 {
+		return nil
+	}
+	if options.Smooth < 1 || options.Smooth > MaxSmoothDays {
+		return ErrInvalidSmoothDays
+	}
+	switch options.Smoothing {
+	case SmoothCentred:
+		if options.Smooth%2 == 0 {
+			return ErrEvenCentredSmooth
+		}
+	case SmoothTrailing:
+	default:
+		return ErrUnknownSmoothing
+	}
+	return nil
+}
+
+// ValidateResampleRange checks that a range isn't too long to be filled or smoothed
+func ValidateResampleRange(from time.Time, to time.Time) error {
+	if to.Sub(from) >= MaxResampleDays*24*time.Hour {
+		return ErrResampleRange
+	}
+	return nil
+}
+
+// Margin returns the number of days before and after a range whose samples are needed to fill and smooth it
+func (options *ResampleOptions) Margin() (before int, after int) {
+	before, after = options.window()
+	if options.Fill == FillPrevious || options.Fill == FillInterpolate {
+		before += FillLookbackDays
+	}
+	if options.Fill == FillInterpolate {
+		after += FillLookbackDays
+	}
+	return before, after
+}
+
+// window returns the number of days before and after a day that its moving average is taken over
+func (options *ResampleOptions) window() (before int, after int) {
+	if options.Smooth == 0 {
+		return 0, 0
+	}
+	if options.Smoothing == SmoothTrailing {
+		return options.Smooth - 1, 0
+	}
+	return options.Smooth / 2, options.Smooth / 2
+}
+
+// SeriesDay is a day of a resampled series. Sample is new, without a predicted count, for days without samples.
+type SeriesDay struct {
+	Sample *dataaccess.PollenSample
+	// Status is empty when the series isn't filled
+	Status DayStatus
+	// Smoothed is the moving average of the counts, nil if no day of its window has one
+	Smoothed *float64
+}
+
+// ResampleSeries puts the samples of a pollen type and location on the days from from to to. samples may
+// include the days of the margin, which the fill method and the moving average use but which are left out.
+// The days of the margin are filled too, so filled counts are averaged like measured ones.
+func ResampleSeries(samples []*dataaccess.PollenSample, pollenType dataaccess.PollenType, location *dataaccess.Location,
+	from time.Time, to time.Time, options *ResampleOptions) []*SeriesDay {
+	before, after := options.Margin()
+	start := from.AddDate(0, 0, -before)
+	end := to.AddDate(0, 0, after)
+	if end.Before(start) {
+		return []*SeriesDay{}
+	}
+
+	days := []*SeriesDay{}
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		days = append(days, &SeriesDay{Sample: &dataaccess.PollenSample{Date: date, PollenType: pollenType, Location: *location}})
+	}
+	sampled := make([]bool, len(days))
+	for _, sample := range samples {
+		index := int(math.Round(sample.Date.Sub(start).Hours() / 24))
+		if index >= 0 && index < len(days) {
+			days[index].Sample = sample
+			sampled[index] = true
+		}
+	}
+
+	counts := make([]*float64, len(days))
+	for i, day := range days {
+		if day.Sample.PollenCount != nil {
+			count := float64(*day.Sample.PollenCount)
+			counts[i] = &count
+		}
+	}
+	if options.Fill != "" {
+		fillSeries(days, counts, options.Fill)
+	}
+	if options.Smooth > 0 {
+		windowBefore, windowAfter := options.window()
+		smoothSeries(days, counts, windowBefore, windowAfter)
+	}
+
+	results := []*SeriesDay{}
+	for i := before; i < len(days)-after; i++ {
+		if options.Fill != "" || sampled[i] {
+			results = append(results, days[i])
+		}
+	}
+	return results
+}
+
+// fillSeries sets the status of the days and fills the counts of those without a measured count. The filled
+// counts are set on copies of the samples, so samples of the repository aren't changed.
+func fillSeries(days []*SeriesDay, counts []*float64, method FillMethod) {
+	var measured []int
+	for i := range days {
+		if counts[i] != nil {
+			days[i].Status = DayMeasured
+			measured = append(measured, i)
+		} else {
+			days[i].Status = DayMissing
+		}
+	}
+	if method == FillNull {
+		return
+	}
+
+	for i, day := range days {
+		if counts[i] != nil {
+			continue
+		}
+		// next is the first measured day after i, and next-1 the last one before it
+		next := sort.SearchInts(measured, i)
+		if next == 0 || (method == FillInterpolate && next == len(measured)) {
+			continue
+		}
+		previous := measured[next-1]
+		value := *counts[previous]
+		if method == FillInterpolate {
+			following := measured[next]
+			share := float64(i-previous) / float64(following-previous)
+			value += share * (*counts[following] - value)
+		}
+		count := int(math.Round(value))
+		filled := *day.Sample
+		filled.PollenCount = &count
+		day.Sample = &filled
+		day.Status = DayFilled
+		filledCount := float64(count)
+		counts[i] = &filledCount
+	}
+}
+
+// smoothSeries sets the moving average of each day over the days from before days before it to after days after it
+func smoothSeries(days []*SeriesDay, counts []*float64, before int, after int) {
+	for i, day := range days {
+		var sum float64
+		var n int
+		for j := i - before; j <= i+after; j++ {
+			if j >= 0 && j < len(days) && counts[j] != nil {
+				sum += *counts[j]
+				n++
+			}
+		}
+		if n > 0 {
+			mean := sum / float64(n)
+			day.Smoothed = &mean
+		}
+	}
+}
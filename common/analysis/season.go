@@ -0,0 +1,219 @@
+package analysis
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+// SeasonMethod decides how the start and end of a pollen season are detected
+type SeasonMethod string
+
+const (
+	// SeasonCumulative starts the season on the day the measured counts of the year add up to Percent of
+	// the total of the year, and ends it on the day they add up to 100 - Percent. It needs the whole year,
+	// so the season of an incomplete year never ends and its start is provisional.
+	SeasonCumulative SeasonMethod = "cumulative"
+	// SeasonThreshold starts the season on the first of Days measured days in a row with counts of at least
+	// Threshold, and ends it on the last day of the last such run once Days measured days in a row below
+	// Threshold follow it.
+	SeasonThreshold SeasonMethod = "threshold"
+)
+
+// Defaults of SeasonOptions
+const (
+	DefaultSeasonPercent = 5
+	DefaultSeasonDays    = 3
+)
+
+// Errors of invalid SeasonOptions
+var (
+	ErrUnknownSeasonMethod    = errors.New("method must be cumulative or threshold")
+	ErrInvalidSeasonPercent   = errors.New("percent must be above 0 and below 50")
+	ErrInvalidSeasonThreshold = errors.New("threshold must be above 0")
+	ErrInvalidSeasonDays      = errors.New("days must be at least 1")
+)
+
+// SeasonOptions configure the detection of a season
+type SeasonOptions struct {
+	Method SeasonMethod
+	// Percent is used by SeasonCumulative
+	Percent float64
+	// Threshold and Days are used by SeasonThreshold
+	Threshold float64
+	Days      int
+	// Thresholds of the pollen type decide which days are high
+	Thresholds dataaccess.Thresholds
+	// Complete tells if the samples are of a year that is over
+	Complete bool
+}
+
+// Validate checks the options used by the method
+func (options *SeasonOptions) Validate() error {
+	switch options.Method {
+	case SeasonCumulative:
+		if options.Percent <= 0 || options.Percent >= 50 {
+			return ErrInvalidSeasonPercent
+		}
+	case SeasonThreshold:
+		if options.Threshold <= 0 {
+			return ErrInvalidSeasonThreshold
+		}
+		if options.Days < 1 {
+			return ErrInvalidSeasonDays
+		}
+	default:
+		return ErrUnknownSeasonMethod
+	}
+	return nil
+}
+
+// Season is the pollen season of a year. Start, Peak and End are nil until they are detected.
+type Season struct {
+	Start     *time.Time
+	Peak      *time.Time
+	PeakCount *int
+	End       *time.Time
+	// Ended tells if the season is over. With SeasonThreshold the season is over when Days measured days
+	// in a row below the threshold follow End. With SeasonCumulative it is over when the year is.
+	Ended bool
+	// TotalLoad is the sum of the measured counts from Start to End, or to the last measured day while
+	// the season isn't over
+	TotalLoad int
+	// HighDays is the number of days in the season with a high or very high count, nil if the pollen
+	// type has no levels
+	HighDays *int
+	// MeasuredDays is the number of days of the year with a measured count
+	MeasuredDays int
+}
+
+// measuredDay is a date with a measured count
+type measuredDay struct {
+	date  time.Time
+	count int
+}
+
+// DetectSeason finds the pollen season in the samples of a year. Days without a measured count are skipped.
+func DetectSeason(samples []*dataaccess.PollenSample, options *SeasonOptions) (*Season, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	var days []measuredDay
+	for _, sample := range samples {
+		if sample.PollenCount != nil {
+			days = append(days, measuredDay{date: sample.Date, count: *sample.PollenCount})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].date.Before(days[j].date)
+	})
+
+	var start, end int
+	var ended bool
+	if options.Method == SeasonCumulative {
+		start, end, ended = cumulativeSeason(days, options.Percent, options.Complete)
+	} else {
+		start, end, ended = thresholdSeason(days, options.Threshold, options.Days)
+	}
+
+	season := &Season{MeasuredDays: len(days), Ended: ended}
+	if start < 0 {
+		return season, nil
+	}
+	season.Start = &days[start].date
+	if ended {
+		season.End = &days[end].date
+	}
+
+	var highDays int
+	peak := start
+	for i := start; i <= end; i++ {
+		season.TotalLoad += days[i].count
+		if days[i].count > days[peak].count {
+			peak = i
+		}
+		if options.Thresholds.Level(float64(days[i].count)) >= dataaccess.LevelHigh {
+			highDays++
+		}
+	}
+	season.Peak = &days[peak].date
+	season.PeakCount = &days[peak].count
+	if options.Thresholds.Defined() {
+		season.HighDays = &highDays
+	}
+	return season, nil
+}
+
+// cumulativeSeason returns the indexes of the days the counts add up to percent and 100 - percent of
+// their total. start is -1 if nothing was counted. The total of an incomplete year isn't known, so its
+// season doesn't end, and end is the last day.
+func cumulativeSeason(days []measuredDay, percent float64, complete bool) (start int, end int, ended bool) {
+	var total int
+	for _, day := range days {
+		total += day.count
+	}
+	if total == 0 {
+		return -1, -1, false
+	}
+
+	start, end = -1, len(days)-1
+	var sum int
+	for i, day := range days {
+		sum += day.count
+		share := 100 * float64(sum) / float64(total)
+		if start < 0 && share >= percent {
+			start = i
+		}
+		if complete && share >= 100-percent {
+			end = i
+			break
+		}
+	}
+	return start, end, complete
+}
+
+// thresholdSeason returns the indexes of the first day of the first run, and the last day of the last run,
+// of runDays days with counts of at least threshold. start is -1 if there is no run, and end is the last
+// day while the season isn't over.
+func thresholdSeason(days []measuredDay, threshold float64, runDays int) (start int, end int, ended bool) {
+	start, end = -1, -1
+	var run int
+	for i, day := range days {
+		if float64(day.count) < threshold {
+			run = 0
+			continue
+		}
+		run++
+		if run < runDays {
+			continue
+		}
+		if start < 0 {
+			start = i - runDays + 1
+		}
+		// Extend the season to the last day of the run
+		end = i
+	}
+	if start < 0 {
+		return -1, -1, false
+	}
+	// Days above the threshold after end are too few to be a run, but break the days below it
+	var below int
+	for _, day := range days[end+1:] {
+		if float64(day.count) >= threshold {
+			below = 0
+			continue
+		}
+		below++
+		if below >= runDays {
+			ended = true
+			break
+		}
+	}
+	if !ended {
+		end = len(days) - 1
+	}
+	return start, end, ended
+}
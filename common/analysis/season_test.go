@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+func TestThresholdSeasonEndsAfterDaysBelowThreshold(t *testing.T) {
+	start := time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC)
+	options := &SeasonOptions{Method: SeasonThreshold, Threshold: 10, Days: 3}
+	tests := []struct {
+		name   string
+		counts []int
+		ended  bool
+	}{
+		{"running", []int{0, 20, 20, 20}, false},
+		{"too few days below", []int{0, 20, 20, 20, 0, 5}, false},
+		{"short run after the season", []int{0, 20, 20, 20, 0, 15, 15, 0, 5}, false},
+		{"days below not in a row", []int{0, 20, 20, 20, 0, 15, 0, 15, 0, 5}, false},
+		{"ended", []int{0, 20, 20, 20, 0, 15, 15, 0, 5, 0}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var samples []*dataaccess.PollenSample
+			for i := range test.counts {
+				samples = append(samples, &dataaccess.PollenSample{Date: start.AddDate(0, 0, i), PollenCount: &test.counts[i]})
+			}
+			season, err := DetectSeason(samples, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if season.Ended != test.ended {
+				t.Errorf("ended %v, want %v", season.Ended, test.ended)
+			}
+			if season.Start == nil || !season.Start.Equal(start.AddDate(0, 0, 1)) {
+				t.Errorf("start %v, want %v", season.Start, start.AddDate(0, 0, 1))
+			}
+			if test.ended && (season.End == nil || !season.End.Equal(start.AddDate(0, 0, 3))) {
+				t.Errorf("end %v, want %v", season.End, start.AddDate(0, 0, 3))
+			}
+		})
+	}
+}
+
+func TestCumulativeSeasonEndsWithTheYear(t *testing.T) {
+	start := time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC)
+	counts := []int{0, 10, 20, 40, 20, 10, 0}
+	tests := []struct {
+		name     string
+		days     int
+		complete bool
+		end      *time.Time
+	}{
+		{"complete year", len(counts), true, dateAfter(start, 5)},
+		{"incomplete year", 4, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var samples []*dataaccess.PollenSample
+			for i := range counts[:test.days] {
+				samples = append(samples, &dataaccess.PollenSample{Date: start.AddDate(0, 0, i), PollenCount: &counts[i]})
+			}
+			options := &SeasonOptions{Method: SeasonCumulative, Percent: 5, Complete: test.complete}
+			season, err := DetectSeason(samples, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if season.Ended != test.complete {
+				t.Errorf("ended %v, want %v", season.Ended, test.complete)
+			}
+			if season.Start == nil || !season.Start.Equal(start.AddDate(0, 0, 1)) {
+				t.Errorf("start %v, want %v", season.Start, start.AddDate(0, 0, 1))
+			}
+			if (season.End == nil) != (test.end == nil) || (test.end != nil && !season.End.Equal(*test.end)) {
+				t.Errorf("end %v, want %v", season.End, test.end)
+			}
+		})
+	}
+}
+
+func dateAfter(date time.Time, days int) *time.Time {
+	result := date.AddDate(0, 0, days)
+	return &result
+}
@@ -0,0 +1,134 @@
+package dataaccess
+
+import (
+	"errors"
+	"time"
+)
+
+// Interval is the length of the buckets pollen data is aggregated in
+type Interval string
+
+// Intervals of aggregates. Buckets follow the calendar of the location, as the dates of pollen data do.
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+	// IntervalSeason buckets by meteorological season: spring is March to May, summer June to August,
+	// autumn September to November and winter December to February. December is in the winter of the next year.
+	IntervalSeason Interval = "season"
+)
+
+// ErrUnknownInterval is returned for an interval other than day, week, month and season
+var ErrUnknownInterval = errors.New("interval must be day, week, month or season")
+
+// ValidateInterval checks that an interval is known
+func ValidateInterval(interval Interval) error {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalSeason:
+		return nil
+	}
+	return ErrUnknownInterval
+}
+
+// PollenAggregate sums up the pollen data of the dates from Start to End. Mean, Max and PredictedMean are nil
+// when the bucket has no measured or predicted counts.
+type PollenAggregate struct {
+	// Start and End are the first and last date of the bucket, also when the range aggregated covers only part of it
+	Start      time.Time
+	End        time.Time
+	PollenType PollenType
+	Location   Location
+	// MeasuredDays is the number of dates with a measured count, which Sum, Mean and Max are of
+	MeasuredDays int
+	Sum          int
+	Mean         *float64
+	Max          *int
+	// PredictedDays is the number of dates with a predicted count, which PredictedMean is of
+	PredictedDays int
+	PredictedMean *float64
+}
+
+// BucketStart returns the first date of the bucket of date. Weeks start on Monday.
+func (interval Interval) BucketStart(date time.Time) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case IntervalMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalSeason:
+		// Seasons start in March, June, September and December
+		if date.Month() < time.March {
+			return time.Date(date.Year()-1, time.December, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return time.Date(date.Year(), date.Month()-(date.Month()-time.March)%3, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return date
+}
+
+// BucketEnd returns the last date of the bucket starting on start
+func (interval Interval) BucketEnd(start time.Time) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 6)
+	case IntervalMonth:
+		return start.AddDate(0, 1, -1)
+	case IntervalSeason:
+		return start.AddDate(0, 3, -1)
+	}
+	return start
+}
+
+// aggregatePollen buckets samples by location, pollen type and interval, sorted like the samples of
+// GetPollenFromRanges. Buckets without samples are left out.
+func aggregatePollen(samples []*PollenSample, interval Interval) []*PollenAggregate {
+	samples = append([]*PollenSample(nil), samples...)
+	sortPollenSeries(samples)
+
+	results := []*PollenAggregate{}
+	var aggregate *PollenAggregate
+	var predictedSum float64
+	for _, sample := range samples {
+		start := interval.BucketStart(sample.Date)
+		if aggregate == nil || !aggregate.Start.Equal(start) ||
+			aggregate.PollenType != sample.PollenType || aggregate.Location.Location != sample.Location.Location {
+			finishAggregate(aggregate, predictedSum)
+			aggregate = &PollenAggregate{
+				Start:      start,
+				End:        interval.BucketEnd(start),
+				PollenType: sample.PollenType,
+				Location:   sample.Location,
+			}
+			predictedSum = 0
+			results = append(results, aggregate)
+		}
+		if sample.PollenCount != nil {
+			count := *sample.PollenCount
+			aggregate.MeasuredDays++
+			aggregate.Sum += count
+			if aggregate.Max == nil || count > *aggregate.Max {
+				aggregate.Max = &count
+			}
+		}
+		if sample.PredictedPollenCount != nil {
+			aggregate.PredictedDays++
+			predictedSum += float64(*sample.PredictedPollenCount)
+		}
+	}
+	finishAggregate(aggregate, predictedSum)
+	return results
+}
+
+// finishAggregate calculates the means of an aggregate
+func finishAggregate(aggregate *PollenAggregate, predictedSum float64) {
+	if aggregate == nil {
+		return
+	}
+	if aggregate.MeasuredDays > 0 {
+		mean := float64(aggregate.Sum) / float64(aggregate.MeasuredDays)
+		aggregate.Mean = &mean
+	}
+	if aggregate.PredictedDays > 0 {
+		predictedMean := predictedSum / float64(aggregate.PredictedDays)
+		aggregate.PredictedMean = &predictedMean
+	}
+}
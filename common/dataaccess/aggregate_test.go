@@ -0,0 +1,75 @@
+package dataaccess
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestAggregatesOfSQLMatchGo checks that the buckets grouped by SQLite are those summed up by aggregatePollen
+func TestAggregatesOfSQLMatchGo(t *testing.T) {
+	repo := newTestRepository(t, nil)
+	if err := repo.UpdateLocation(&Location{Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen"}); err != nil {
+		t.Fatal(err)
+	}
+	// Dates from December to the next March, so seasons and weeks cross years, with gaps and unknown counts
+	start := time.Date(2019, time.November, 25, 0, 0, 0, 0, time.UTC)
+	var samples []*PollenSample
+	for i := 0; i < 130; i++ {
+		sample := &PollenSample{Date: start.AddDate(0, 0, i), PollenType: PollenType(i % 2), Location: Location{Location: 0}}
+		if i%7 != 3 {
+			count := i * 3 % 17
+			sample.PollenCount = &count
+		}
+		if i%5 != 0 {
+			predicted := float32(i%11) + 0.5
+			sample.PredictedPollenCount = &predicted
+		}
+		if i%13 != 6 {
+			samples = append(samples, sample)
+		}
+	}
+	if _, err := repo.BulkUpsertPollenSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	query := &PollenQuery{From: start.AddDate(0, 0, 3), To: start.AddDate(0, 0, 120)}
+	stored, err := repo.GetPollenFromRanges(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, interval := range []Interval{IntervalDay, IntervalWeek, IntervalMonth, IntervalSeason} {
+		t.Run(string(interval), func(t *testing.T) {
+			aggregates, err := repo.GetPollenAggregates(query, interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := aggregatePollen(stored, interval)
+			if len(aggregates) != len(want) {
+				t.Fatalf("got %d aggregates, want %d", len(aggregates), len(want))
+			}
+			for i, aggregate := range aggregates {
+				if !reflect.DeepEqual(aggregate, want[i]) {
+					t.Errorf("got %s, want %s", describeAggregate(aggregate), describeAggregate(want[i]))
+				}
+			}
+		})
+	}
+}
+
+// describeAggregate formats an aggregate with the values of its pointers
+func describeAggregate(aggregate *PollenAggregate) string {
+	return fmt.Sprintf("%s to %s type %d location %d: %d days sum %d mean %v max %v, %d predicted days mean %v",
+		aggregate.Start.Format("2006-01-02"), aggregate.End.Format("2006-01-02"), aggregate.PollenType,
+		aggregate.Location.Location, aggregate.MeasuredDays, aggregate.Sum, pointerValue(aggregate.Mean),
+		pointerValue(aggregate.Max), aggregate.PredictedDays, pointerValue(aggregate.PredictedMean))
+}
+
+func pointerValue(pointer interface{}) interface{} {
+	value := reflect.ValueOf(pointer)
+	if value.IsNil() {
+		return nil
+	}
+	return value.Elem().Interface()
+}
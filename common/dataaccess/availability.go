@@ -0,0 +1,92 @@
+package dataaccess
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// PollenAvailability tells which dates a location has pollen data for, for a pollen type
+type PollenAvailability struct {
+	Location   int
+	PollenType PollenType
+	FirstDate  time.Time
+	LastDate   time.Time
+}
+
+// timeLayouts are the formats a timestamp may come back in when the driver doesn't convert it, e.g.
+// from MIN and MAX on SQLite, which lose the column type
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// timeScanner scans a timestamp whether the driver returns it as a time or as text
+type timeScanner struct {
+	Time time.Time
+}
+
+// Scan implements sql.Scanner
+func (scanner *timeScanner) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case time.Time:
+		scanner.Time = value
+		return nil
+	case string:
+		return scanner.parse(value)
+	case []byte:
+		return scanner.parse(string(value))
+	case nil:
+		scanner.Time = time.Time{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T as a timestamp", value)
+}
+
+func (scanner *timeScanner) parse(value string) error {
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			scanner.Time = parsed.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a timestamp", value)
+}
+
+// GetPollenAvailability lists, for every location and pollen type with pollen data, the first and last
+// date with a measured or predicted pollen count. Ordered by location and pollen type.
+func (repo *PollenRepository) GetPollenAvailability() ([]*PollenAvailability, error) {
+	rows, err := repo.PreparedStatements["FetchPollenAvailability"].Query()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	var results []*PollenAvailability
+	for rows.Next() {
+		availability := &PollenAvailability{}
+		var firstDate, lastDate timeScanner
+		err := rows.Scan(&availability.Location, &availability.PollenType, &firstDate, &lastDate)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+			return nil, err
+		}
+		availability.FirstDate = firstDate.Time
+		availability.LastDate = lastDate.Time
+		results = append(results, availability)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,60 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Names of the supported drivers in db.toml
+const (
+	DriverIgnite   = "ignite"
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// backend holds everything that differs between the databases the repository can run on.
+// Queries are written with ? placeholders and standard SQL everywhere else.
+type backend interface {
+	// connect opens the database described by config and makes it ready for use
+	connect(config *DbConnectionConfig) (*sql.DB, error)
+	// rebind rewrites a query using ? placeholders to the placeholder syntax of the database
+	rebind(query string) string
+	// upsertQuery builds a statement inserting rows into table, or replacing them if a row with the same
+	// keyColumns exists. It takes the key columns followed by the value columns of each row as arguments.
+	upsertQuery(table string, keyColumns []string, valueColumns []string, rows int) string
+	// upsertNonNullQuery is upsertQuery, except that NULL values leave the values of an existing row as they
+	// are. Backends that don't upsertKeepsOtherColumns can't, and write NULL values like upsertQuery.
+	upsertNonNullQuery(table string, keyColumns []string, valueColumns []string, rows int) string
+	// upsertKeepsOtherColumns tells if upsertQuery leaves the columns not in valueColumns untouched when
+	// the row exists. Otherwise upsertColumns falls back to an update followed by an insert.
+	upsertKeepsOtherColumns() bool
+	// bucketStart returns an expression of the first date of the bucket of the date in column, as text in
+	// the format 2006-01-02, or an empty string if the database can't compute it
+	bucketStart(interval Interval, column string) string
+	// supportsTransactions tells if statements, including schema changes, can run in a transaction
+	supportsTransactions() bool
+}
+
+// getBackend finds the backend for a driver name. An empty name means Ignite.
+func getBackend(driver string) (backend, error) {
+	switch driver {
+	case "", DriverIgnite:
+		return &igniteBackend{}, nil
+	case DriverSQLite, DriverPostgres:
+		return &sqlBackend{driver: driver}, nil
+	default:
+		return nil, fmt.Errorf("Unknown database driver: %s", driver)
+	}
+}
+
+// placeholders returns count comma separated ? placeholders
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+// valueRows returns the rows of a VALUES clause, each with count placeholders
+func valueRows(count int, rows int) string {
+	row := "(" + placeholders(count) + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
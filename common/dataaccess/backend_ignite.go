@@ -0,0 +1,61 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/amsokol/ignite-go-client/binary/v1"
+	// Here to import the sql driver
+	_ "github.com/amsokol/ignite-go-client/sql"
+)
+
+// igniteBackend stores data in Apache Ignite through its SQL driver
+type igniteBackend struct{}
+
+func (backend *igniteBackend) connect(config *DbConnectionConfig) (*sql.DB, error) {
+	db, err := sql.Open("ignite", config.SQLConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache has to exist before the SQL driver can use it
+	client, err := ignite.Connect(config.ConnInfo)
+	if err != nil {
+		return db, err
+	}
+	defer client.Close()
+	err = client.CacheGetOrCreateWithName(config.CacheName)
+	return db, err
+}
+
+func (backend *igniteBackend) rebind(query string) string {
+	return query
+}
+
+func (backend *igniteBackend) upsertQuery(table string, keyColumns []string, valueColumns []string, rows int) string {
+	columns := append(append([]string{}, keyColumns...), valueColumns...)
+	return fmt.Sprintf(`
+		MERGE INTO %s (%s) 
+		VALUES %s`,
+		table, strings.Join(columns, ", "), valueRows(len(columns), rows))
+}
+
+// MERGE can't see the existing row, so NULL values are written too
+func (backend *igniteBackend) upsertNonNullQuery(table string, keyColumns []string, valueColumns []string, rows int) string {
+	return backend.upsertQuery(table, keyColumns, valueColumns, rows)
+}
+
+// The date functions of Ignite can't find the start of a week or season, so aggregates are summed up in Go
+func (backend *igniteBackend) bucketStart(interval Interval, column string) string {
+	return ""
+}
+
+func (backend *igniteBackend) supportsTransactions() bool {
+	return false
+}
+
+// MERGE in Ignite replaces the whole row, so the columns not given are lost
+func (backend *igniteBackend) upsertKeepsOtherColumns() bool {
+	return false
+}
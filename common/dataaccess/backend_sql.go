@@ -0,0 +1,114 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	// Here to import the sql drivers
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlBackend stores data in SQLite or PostgreSQL
+type sqlBackend struct {
+	driver string
+}
+
+func (backend *sqlBackend) connect(config *DbConnectionConfig) (*sql.DB, error) {
+	db, err := sql.Open(backend.driver, config.SQLConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	if backend.driver == DriverSQLite {
+		// SQLite only allows a single writer, so concurrent upserts would fail with "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+	return db, db.Ping()
+}
+
+func (backend *sqlBackend) rebind(query string) string {
+	if backend.driver != DriverPostgres {
+		return query
+	}
+	// PostgreSQL uses numbered placeholders
+	var builder strings.Builder
+	parameter := 0
+	for _, char := range query {
+		if char == '?' {
+			parameter++
+			builder.WriteString("$" + strconv.Itoa(parameter))
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+	return builder.String()
+}
+
+func (backend *sqlBackend) upsertQuery(table string, keyColumns []string, valueColumns []string, rows int) string {
+	columns := append(append([]string{}, keyColumns...), valueColumns...)
+	updates := make([]string, len(valueColumns))
+	for i, column := range valueColumns {
+		updates[i] = column + " = excluded." + column
+	}
+	return fmt.Sprintf(`
+		INSERT INTO %s (%s) 
+		VALUES %s
+		ON CONFLICT (%s) DO UPDATE SET %s`,
+		table, strings.Join(columns, ", "), valueRows(len(columns), rows),
+		strings.Join(keyColumns, ", "), strings.Join(updates, ", "))
+}
+
+func (backend *sqlBackend) upsertNonNullQuery(table string, keyColumns []string, valueColumns []string, rows int) string {
+	columns := append(append([]string{}, keyColumns...), valueColumns...)
+	updates := make([]string, len(valueColumns))
+	for i, column := range valueColumns {
+		updates[i] = fmt.Sprintf("%s = COALESCE(excluded.%s, %s.%s)", column, column, table, column)
+	}
+	return fmt.Sprintf(`
+		INSERT INTO %s (%s) 
+		VALUES %s
+		ON CONFLICT (%s) DO UPDATE SET %s`,
+		table, strings.Join(columns, ", "), valueRows(len(columns), rows),
+		strings.Join(keyColumns, ", "), strings.Join(updates, ", "))
+}
+
+func (backend *sqlBackend) bucketStart(interval Interval, column string) string {
+	if backend.driver == DriverPostgres {
+		var start string
+		switch interval {
+		case IntervalDay:
+			start = column
+		case IntervalWeek:
+			start = fmt.Sprintf("date_trunc('week', %s)", column)
+		case IntervalMonth:
+			start = fmt.Sprintf("date_trunc('month', %s)", column)
+		case IntervalSeason:
+			// Seasons start in March, June, September and December
+			start = fmt.Sprintf("date_trunc('month', %s) - ((CAST(EXTRACT(MONTH FROM %s) AS INT) + 9) %% 3) * INTERVAL '1 month'",
+				column, column)
+		}
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", start)
+	}
+
+	switch interval {
+	case IntervalWeek:
+		// Weeks start on Monday, six days before the Sunday ending them
+		return fmt.Sprintf("date(%s, 'weekday 0', '-6 days')", column)
+	case IntervalMonth:
+		return fmt.Sprintf("date(%s, 'start of month')", column)
+	case IntervalSeason:
+		return fmt.Sprintf("date(%s, 'start of month', '-' || ((CAST(strftime('%%m', %s) AS INTEGER) + 9) %% 3) || ' months')",
+			column, column)
+	}
+	return fmt.Sprintf("date(%s)", column)
+}
+
+func (backend *sqlBackend) supportsTransactions() bool {
+	return true
+}
+
+func (backend *sqlBackend) upsertKeepsOtherColumns() bool {
+	return true
+}
@@ -0,0 +1,198 @@
+package dataaccess
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// bulkBatchSize is the number of rows written by each statement of a bulk import
+const bulkBatchSize = 100
+
+// BulkResult reports the outcome of a bulk import
+type BulkResult struct {
+	Inserted int
+	Updated  int
+	Failed   int
+}
+
+// BulkUpsertPollenSamples insert/updates many pollen samples, e.g. a full history, in batches.
+// Samples with the same date, pollen type and location are written once, using the last of them.
+//
+// On backends with transactions the import is all or nothing: if anything fails, nothing is written,
+// every sample is reported as failed and the error is returned. Without transactions (Ignite), rows
+// of a failing batch are retried one by one, and the ones still failing are counted in Failed.
+func (repo *PollenRepository) BulkUpsertPollenSamples(samples []*PollenSample) (*BulkResult, error) {
+	samples = uniquePollenSamples(samples)
+	result := &BulkResult{}
+	err := repo.withTransaction(func(exec executor) error {
+		for start := 0; start < len(samples); start += bulkBatchSize {
+			end := start + bulkBatchSize
+			if end > len(samples) {
+				end = len(samples)
+			}
+			if err := repo.upsertBatch(exec, samples[start:end], result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(fmt.Errorf("failed bulk insert: %v", err))
+		return &BulkResult{Failed: len(samples)}, err
+	}
+	return result, nil
+}
+
+// upsertBatch writes a batch of samples with a single statement and adds the outcome to result
+func (repo *PollenRepository) upsertBatch(exec executor, batch []*PollenSample, result *BulkResult) error {
+	existing, err := repo.existingKeys(exec, batch)
+	if err != nil {
+		return err
+	}
+
+	err = repo.upsertPollenSamples(exec, batch, existing)
+	if err == nil {
+		for _, pollen := range batch {
+			if existing[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)] {
+				result.Updated++
+			} else {
+				result.Inserted++
+			}
+		}
+		return nil
+	}
+	if repo.backend.supportsTransactions() {
+		return err
+	}
+
+	// Without a transaction the batch may be partly written, and it is retried row by row to find the failures
+	log.Println(fmt.Errorf("failed batch insert, retrying rows one at a time: %v", err))
+	for _, pollen := range batch {
+		err := repo.upsertPollenSamples(exec, []*PollenSample{pollen}, existing)
+		switch {
+		case err != nil:
+			log.Println(fmt.Errorf("failed insert data for %v: %v", pollen.Date, err))
+			result.Failed++
+		case existing[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)]:
+			result.Updated++
+		default:
+			result.Inserted++
+		}
+	}
+	return nil
+}
+
+// upsertPollenSamples writes samples, as far as possible with a single statement. Unknown counts never overwrite the counts of
+// existing rows: where the upsert replaces whole rows (Ignite), the existing rows of samples with an unknown
+// count are updated column by column instead.
+func (repo *PollenRepository) upsertPollenSamples(exec executor, samples []*PollenSample, existing map[pollenKey]bool) error {
+	whole := samples
+	var partial []*PollenSample
+	if !repo.backend.upsertKeepsOtherColumns() {
+		whole = nil
+		for _, pollen := range samples {
+			complete := pollen.PollenCount != nil && pollen.PredictedPollenCount != nil
+			if complete || !existing[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)] {
+				whole = append(whole, pollen)
+			} else {
+				partial = append(partial, pollen)
+			}
+		}
+	}
+
+	if len(whole) > 0 {
+		args := make([]interface{}, 0, len(whole)*5)
+		for _, pollen := range whole {
+			args = append(args, pollen.Date, int(pollen.PollenType), pollen.Location.Location,
+				pollen.PollenCount, pollen.PredictedPollenCount)
+		}
+		if _, err := exec.Exec(repo.upsertPollenSampleQuery(len(whole)), args...); err != nil {
+			return err
+		}
+	}
+	for _, pollen := range partial {
+		var columns []string
+		var values []interface{}
+		if pollen.PollenCount != nil {
+			columns, values = append(columns, "PollenCount"), append(values, pollen.PollenCount)
+		}
+		if pollen.PredictedPollenCount != nil {
+			columns, values = append(columns, "PredictedPollenCount"), append(values, pollen.PredictedPollenCount)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		err := repo.upsertColumns(exec, "PollenArchive",
+			[]string{"Date", "PollenType", "Location"},
+			[]interface{}{pollen.Date, int(pollen.PollenType), pollen.Location.Location},
+			columns, values)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existingKeys finds which samples of batch already have a row in PollenArchive
+func (repo *PollenRepository) existingKeys(exec executor, batch []*PollenSample) (map[pollenKey]bool, error) {
+	query := &PollenQuery{From: batch[0].Date, To: batch[0].Date}
+	wanted := make(map[pollenKey]bool)
+	for _, pollen := range batch {
+		if pollen.Date.Before(query.From) {
+			query.From = pollen.Date
+		}
+		if pollen.Date.After(query.To) {
+			query.To = pollen.Date
+		}
+		if !containsPollenType(query.PollenTypes, pollen.PollenType) {
+			query.PollenTypes = append(query.PollenTypes, pollen.PollenType)
+		}
+		if !containsInt(query.Locations, pollen.Location.Location) {
+			query.Locations = append(query.Locations, pollen.Location.Location)
+		}
+		wanted[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)] = true
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(`
+		SELECT
+			Date,
+			PollenType,
+			Location
+		FROM PollenArchive`)
+	args := query.writeConditions(&builder)
+	rows, err := exec.Query(repo.backend.rebind(builder.String()), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	existing := make(map[pollenKey]bool)
+	for rows.Next() {
+		var pollen PollenSample
+		if err := rows.Scan(&pollen.Date, &pollen.PollenType, &pollen.Location.Location); err != nil {
+			return nil, err
+		}
+		key := newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)
+		if wanted[key] {
+			existing[key] = true
+		}
+	}
+	return existing, rows.Err()
+}
+
+// uniquePollenSamples removes samples with the same key as a later sample, keeping the order otherwise.
+// A statement can't write the same row twice.
+func uniquePollenSamples(samples []*PollenSample) []*PollenSample {
+	last := make(map[pollenKey]int)
+	for i, pollen := range samples {
+		last[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)] = i
+	}
+	results := make([]*PollenSample, 0, len(last))
+	for i, pollen := range samples {
+		if last[newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)] == i {
+			results = append(results, pollen)
+		}
+	}
+	return results
+}
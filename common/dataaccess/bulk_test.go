@@ -0,0 +1,62 @@
+package dataaccess
+
+import (
+	"testing"
+	"time"
+)
+
+// importOverExistingCounts bulk imports and upserts samples without counts over stored counts, and checks
+// that the stored counts are kept
+func importOverExistingCounts(t *testing.T, store PollenStore) {
+	start := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var stored []*PollenSample
+	for i := 0; i < 3; i++ {
+		count, predicted := 10+i, float32(20+i)
+		stored = append(stored, &PollenSample{Date: start.AddDate(0, 0, i), Location: Location{Location: 0},
+			PollenCount: &count, PredictedPollenCount: &predicted})
+	}
+	if _, err := store.BulkUpsertPollenSamples(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	newCount, newPredicted := 30, float32(40)
+	imported := []*PollenSample{
+		{Date: start, Location: Location{Location: 0}},
+		{Date: start.AddDate(0, 0, 1), Location: Location{Location: 0}, PollenCount: &newCount},
+	}
+	result, err := store.BulkUpsertPollenSamples(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 2 || result.Inserted != 0 {
+		t.Errorf("updated %d and inserted %d, want 2 and 0", result.Updated, result.Inserted)
+	}
+	err = store.UpsertPollenSample(&PollenSample{Date: start.AddDate(0, 0, 2), Location: Location{Location: 0},
+		PredictedPollenCount: &newPredicted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := store.GetPollenFromRange(start, start.AddDate(0, 0, 2), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		count     int
+		predicted float32
+	}{{10, 20}, {30, 21}, {12, 40}}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for i, sample := range samples {
+		if sample.PollenCount == nil || *sample.PollenCount != want[i].count ||
+			sample.PredictedPollenCount == nil || *sample.PredictedPollenCount != want[i].predicted {
+			t.Errorf("%s: pollen count %v, predicted pollen count %v, want %d and %v", sample.Date.Format("2006-01-02"),
+				sample.PollenCount, sample.PredictedPollenCount, want[i].count, want[i].predicted)
+		}
+	}
+}
+
+func TestImportOverExistingCounts(t *testing.T) {
+	forEachStore(t, importOverExistingCounts)
+}
@@ -0,0 +1,79 @@
+package dataaccess
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Clock tells the current time. It is injected where "today" matters, so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a function used as a Clock
+type ClockFunc func() time.Time
+
+// Now calls the function
+func (clock ClockFunc) Now() time.Time {
+	return clock()
+}
+
+// SystemClock is the clock of the system
+var SystemClock Clock = ClockFunc(time.Now)
+
+// ValidateTimeZone checks that name is a known IANA time zone
+func ValidateTimeZone(name string) error {
+	if name == "" {
+		return fmt.Errorf("Time zone is required")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("Unknown time zone: %s", name)
+	}
+	return nil
+}
+
+// TimeLocation returns the time zone of the location. Locations without a valid time zone use UTC.
+func (location *Location) TimeLocation() *time.Location {
+	if location.TimeZone == "" {
+		return time.UTC
+	}
+	timeLocation, err := time.LoadLocation(location.TimeZone)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to load time zone of location %v: %v", location.Location, err))
+		return time.UTC
+	}
+	return timeLocation
+}
+
+// Date converts a timestamp to the date used in the repository, following the calendar of the location
+func (location *Location) Date(timestamp time.Time) time.Time {
+	return TimestampToDate(timestamp.In(location.TimeLocation()))
+}
+
+// EndOfDay returns the instant the date ends in the time zone of the location, which is 23 or 25 hours after
+// it starts on the days daylight saving time begins or ends
+func (location *Location) EndOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, location.TimeLocation()).UTC()
+}
+
+// Today is the current date in the calendar of the location
+func (location *Location) Today(clock Clock) time.Time {
+	return location.Date(clock.Now())
+}
+
+// RelativeDate resolves "yesterday", "today" and "tomorrow" to a date in the calendar of the location.
+// The second return value is false for any other name.
+func (location *Location) RelativeDate(clock Clock, name string) (time.Time, bool) {
+	today := location.Today(clock)
+	switch name {
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "today":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	default:
+		return time.Time{}, false
+	}
+}
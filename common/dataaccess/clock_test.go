@@ -0,0 +1,126 @@
+package dataaccess
+
+import (
+	"testing"
+	"time"
+)
+
+var copenhagen = Location{Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen"}
+
+// fixedClock is a Clock stopped at an instant
+func fixedClock(instant string) Clock {
+	now, err := time.Parse(time.RFC3339, instant)
+	if err != nil {
+		panic(err)
+	}
+	return ClockFunc(func() time.Time { return now })
+}
+
+func mustDate(value string) time.Time {
+	result, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Daylight saving time begins on the last Sunday of March, 2020-03-29, and ends on the last Sunday of
+// October, 2020-10-25. Copenhagen is UTC+1 in winter and UTC+2 in summer.
+func TestRelativeDatesAroundDaylightSavingTime(t *testing.T) {
+	tests := []struct {
+		now       string
+		today     string
+		tomorrow  string
+		yesterday string
+	}{
+		{"2020-03-28T22:59:00Z", "2020-03-28", "2020-03-29", "2020-03-27"},
+		{"2020-03-28T23:00:00Z", "2020-03-29", "2020-03-30", "2020-03-28"},
+		{"2020-03-29T21:59:00Z", "2020-03-29", "2020-03-30", "2020-03-28"},
+		{"2020-03-29T22:00:00Z", "2020-03-30", "2020-03-31", "2020-03-29"},
+		{"2020-10-24T21:59:00Z", "2020-10-24", "2020-10-25", "2020-10-23"},
+		{"2020-10-24T22:00:00Z", "2020-10-25", "2020-10-26", "2020-10-24"},
+		{"2020-10-25T22:59:00Z", "2020-10-25", "2020-10-26", "2020-10-24"},
+		{"2020-10-25T23:00:00Z", "2020-10-26", "2020-10-27", "2020-10-25"},
+	}
+	for _, test := range tests {
+		clock := fixedClock(test.now)
+		if today := copenhagen.Today(clock); !today.Equal(mustDate(test.today)) {
+			t.Errorf("%s: today is %s, want %s", test.now, today.Format("2006-01-02"), test.today)
+		}
+		for name, want := range map[string]string{"tomorrow": test.tomorrow, "yesterday": test.yesterday} {
+			if got, _ := copenhagen.RelativeDate(clock, name); !got.Equal(mustDate(want)) {
+				t.Errorf("%s: %s is %s, want %s", test.now, name, got.Format("2006-01-02"), want)
+			}
+		}
+	}
+}
+
+func TestEndOfDayAroundDaylightSavingTime(t *testing.T) {
+	tests := []struct {
+		date string
+		end  string
+	}{
+		{"2020-03-28", "2020-03-28T23:00:00Z"},
+		// The day daylight saving time begins is 23 hours long
+		{"2020-03-29", "2020-03-29T22:00:00Z"},
+		{"2020-10-24", "2020-10-24T22:00:00Z"},
+		// The day daylight saving time ends is 25 hours long
+		{"2020-10-25", "2020-10-25T23:00:00Z"},
+	}
+	for _, test := range tests {
+		end := copenhagen.EndOfDay(mustDate(test.date))
+		if end.Format(time.RFC3339) != test.end {
+			t.Errorf("%s ends at %s, want %s", test.date, end.Format(time.RFC3339), test.end)
+		}
+	}
+}
+
+// forecastsIssuedOn checks which of the forecasts issued around the change of daylight saving time on day
+// is returned as issued on the day before, the day and the day after
+func forecastsIssuedOn(t *testing.T, store PollenStore) {
+	target := mustDate("2020-11-01")
+	issued := []string{
+		// Late on the Saturday and early on the Sunday DST begins
+		"2020-03-28T22:30:00Z", "2020-03-28T23:30:00Z",
+		// Late on that Sunday and early on the Monday, an hour earlier in UTC
+		"2020-03-29T21:30:00Z", "2020-03-29T22:30:00Z",
+		// Late on the Saturday and early on the Sunday DST ends
+		"2020-10-24T21:30:00Z", "2020-10-24T22:30:00Z",
+		// Late on that Sunday and early on the Monday, an hour later in UTC
+		"2020-10-25T22:30:00Z", "2020-10-25T23:30:00Z",
+	}
+	for i, instant := range issued {
+		issuedAt, _ := time.Parse(time.RFC3339, instant)
+		err := store.InsertPollenForecast(&PollenForecast{TargetDate: target, Location: 0, IssuedAt: issuedAt,
+			LeadDays: 1, PredictedPollenCount: float32(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		issued string
+		want   string
+	}{
+		{"2020-03-28", "2020-03-28T22:30:00Z"},
+		{"2020-03-29", "2020-03-29T21:30:00Z"},
+		{"2020-03-30", "2020-03-29T22:30:00Z"},
+		{"2020-10-24", "2020-10-24T21:30:00Z"},
+		{"2020-10-25", "2020-10-25T22:30:00Z"},
+		{"2020-10-26", "2020-10-25T23:30:00Z"},
+	}
+	for _, test := range tests {
+		forecast, err := store.GetPollenForecastIssuedOn(target, 0, 0, mustDate(test.issued))
+		if err != nil {
+			t.Errorf("issued on %s: %v", test.issued, err)
+			continue
+		}
+		if got := forecast.IssuedAt.UTC().Format(time.RFC3339); got != test.want {
+			t.Errorf("issued on %s: got the forecast issued at %s, want %s", test.issued, got, test.want)
+		}
+	}
+}
+
+func TestForecastIssuedOn(t *testing.T) {
+	forEachStore(t, forecastsIssuedOn)
+}
@@ -7,11 +7,14 @@ import (
 	"github.com/amsokol/ignite-go-client/binary/v1"
 )
 
-// DbConnectionConfig holds data for connecting to Ignite
+// DbConnectionConfig holds data for connecting to the database
 type DbConnectionConfig struct {
+	// Driver selects the backend: "ignite" (default), "sqlite3" or "postgres"
+	Driver              string
 	SQLConnectionString string
-	ConnInfo            ignite.ConnInfo
-	CacheName           string
+	// ConnInfo and CacheName are only used by Ignite
+	ConnInfo  ignite.ConnInfo
+	CacheName string
 }
 
 func getConfig() *DbConnectionConfig {
@@ -0,0 +1,102 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrMissingFeedTypeID is returned by CompleteFeedStation when no feed type id is known
+var ErrMissingFeedTypeID = errors.New("no feed type id given, and the pollen type has no default")
+
+// CompleteFeedStation checks that the location and pollen type of station exist. If station has no
+// TypeID, the FeedTypeID of the pollen type is used.
+func CompleteFeedStation(store PollenStore, station *FeedStation) error {
+	if _, err := store.GetLocation(station.Location); err != nil {
+		return err
+	}
+	pollenTypes, err := store.GetPollenTypes()
+	if err != nil {
+		return err
+	}
+	for _, pollenType := range pollenTypes {
+		if pollenType.PollenType != station.PollenType {
+			continue
+		}
+		if station.TypeID == 0 {
+			station.TypeID = pollenType.FeedTypeID
+		}
+		if station.TypeID == 0 {
+			return ErrMissingFeedTypeID
+		}
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func rowToFeedStation(row Scanner) (*FeedStation, error) {
+	station := &FeedStation{}
+	err := row.Scan(&station.Location, &station.PollenType, &station.StationID, &station.TypeID)
+	if err != nil {
+		return nil, err
+	}
+	return station, nil
+}
+
+// GetFeedStations fetch all feed station mappings
+func (repo *PollenRepository) GetFeedStations() ([]*FeedStation, error) {
+	var results []*FeedStation
+	rows, err := repo.PreparedStatements["FetchFeedStations"].Query()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		station, err := rowToFeedStation(rows)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+		} else {
+			results = append(results, station)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+	}
+	return results, err
+}
+
+// GetFeedStation fetch the feed station for a location and pollen type.
+// Returns sql.ErrNoRows if the combination isn't mapped.
+func (repo *PollenRepository) GetFeedStation(location int, pollenType PollenType) (*FeedStation, error) {
+	row := repo.PreparedStatements["FetchFeedStation"].QueryRow(location, int(pollenType))
+	return rowToFeedStation(row)
+}
+
+// UpsertFeedStation insert/updates the feed station for a location and pollen type
+func (repo *PollenRepository) UpsertFeedStation(station *FeedStation) error {
+	_, err := repo.DB.Exec(repo.backend.rebind(repo.backend.upsertQuery("FeedStations",
+		[]string{"Location", "PollenType"},
+		[]string{"StationID", "TypeID"}, 1)),
+		station.Location, int(station.PollenType), station.StationID, station.TypeID)
+	if err != nil {
+		log.Println(fmt.Errorf("failed insert data: %v", err))
+	}
+	return err
+}
+
+// DeleteFeedStation deletes the feed station for a location and pollen type.
+// Returns sql.ErrNoRows if the combination isn't mapped.
+func (repo *PollenRepository) DeleteFeedStation(location int, pollenType PollenType) error {
+	result, err := repo.DB.Exec(repo.backend.rebind(`
+		DELETE FROM FeedStations
+		WHERE Location = ? AND PollenType = ?`),
+		location, int(pollenType))
+	if err != nil {
+		log.Println(fmt.Errorf("failed delete data: %v", err))
+		return err
+	}
+	return requireAffectedRows(result)
+}
@@ -0,0 +1,82 @@
+package dataaccess
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// earthRadiusKm is the mean radius of the earth
+const earthRadiusKm = 6371.0
+
+// RadiusFilter limits a search to locations within RadiusKm of a point
+type RadiusFilter struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// LocationDistance is a location and its distance from a point
+type LocationDistance struct {
+	Location   *Location
+	DistanceKm float64
+}
+
+// ValidateCoordinates checks that latitude and longitude are within range
+func ValidateCoordinates(latitude float64, longitude float64) error {
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		return fmt.Errorf("Latitude must be between -90 and 90")
+	}
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("Longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// DistanceKm is the great-circle distance between two points, using the haversine formula
+func DistanceKm(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+	toRadians := math.Pi / 180
+	deltaLatitude := (latitude2 - latitude1) * toRadians
+	deltaLongitude := (longitude2 - longitude1) * toRadians
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(latitude1*toRadians)*math.Cos(latitude2*toRadians)*
+			math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// DistanceKm is the distance from the location to a point. The second return value is false if the
+// location has no coordinates.
+func (location *Location) DistanceKm(latitude float64, longitude float64) (float64, bool) {
+	if location.Latitude == nil || location.Longitude == nil {
+		return 0, false
+	}
+	return DistanceKm(*location.Latitude, *location.Longitude, latitude, longitude), true
+}
+
+// Within tells if the location is inside the radius of filter. A nil filter contains every location.
+func (filter *RadiusFilter) Within(location *Location) bool {
+	if filter == nil {
+		return true
+	}
+	distance, ok := location.DistanceKm(filter.Latitude, filter.Longitude)
+	return ok && distance <= filter.RadiusKm
+}
+
+// NearestLocations ranks locations by their distance to a point, nearest first, and returns at most limit
+// of them. Locations without coordinates are left out.
+func NearestLocations(locations []*Location, latitude float64, longitude float64, limit int) []*LocationDistance {
+	var results []*LocationDistance
+	for _, location := range locations {
+		distance, ok := location.DistanceKm(latitude, longitude)
+		if ok {
+			results = append(results, &LocationDistance{Location: location, DistanceKm: distance})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,116 @@
+package dataaccess
+
+import (
+	"errors"
+)
+
+// Level is a risk level of a pollen count
+type Level int
+
+// Levels from lowest to highest
+const (
+	LevelLow Level = iota
+	LevelModerate
+	LevelHigh
+	LevelVeryHigh
+)
+
+// Levels lists all levels, lowest first
+var Levels = []Level{LevelLow, LevelModerate, LevelHigh, LevelVeryHigh}
+
+func (level Level) String() string {
+	switch level {
+	case LevelLow:
+		return "low"
+	case LevelModerate:
+		return "moderate"
+	case LevelHigh:
+		return "high"
+	case LevelVeryHigh:
+		return "very_high"
+	}
+	return "unknown"
+}
+
+// LevelDescription names and describes a level in English and Danish
+type LevelDescription struct {
+	Level         Level
+	NameEn        string
+	NameDa        string
+	DescriptionEn string
+	DescriptionDa string
+}
+
+// LevelDescriptions describes every level, lowest first
+var LevelDescriptions = []LevelDescription{
+	{
+		Level:         LevelLow,
+		NameEn:        "Low",
+		NameDa:        "Lav",
+		DescriptionEn: "Only the most sensitive allergy sufferers have symptoms.",
+		DescriptionDa: "Kun de mest følsomme allergikere får symptomer.",
+	},
+	{
+		Level:         LevelModerate,
+		NameEn:        "Moderate",
+		NameDa:        "Moderat",
+		DescriptionEn: "Many allergy sufferers have symptoms.",
+		DescriptionDa: "Mange allergikere får symptomer.",
+	},
+	{
+		Level:         LevelHigh,
+		NameEn:        "High",
+		NameDa:        "Høj",
+		DescriptionEn: "Most allergy sufferers have symptoms.",
+		DescriptionDa: "De fleste allergikere får symptomer.",
+	},
+	{
+		Level:         LevelVeryHigh,
+		NameEn:        "Very high",
+		NameDa:        "Meget høj",
+		DescriptionEn: "Most allergy sufferers have strong symptoms.",
+		DescriptionDa: "De fleste allergikere får kraftige symptomer.",
+	},
+}
+
+// Thresholds are the lowest counts of the moderate, high and very high levels of a pollen type. Counts
+// below Moderate are low. When all are 0 the pollen type has no levels.
+type Thresholds struct {
+	Moderate float64
+	High     float64
+	VeryHigh float64
+}
+
+var errInvalidThresholds = errors.New("Thresholds must be all 0, or increase from moderate to high to very high")
+
+// Defined tells if the thresholds are set
+func (thresholds Thresholds) Defined() bool {
+	return thresholds != Thresholds{}
+}
+
+// Validate checks that the thresholds are either all 0, or above 0 and increasing
+func (thresholds Thresholds) Validate() error {
+	if !thresholds.Defined() {
+		return nil
+	}
+	if thresholds.Moderate <= 0 || thresholds.High <= thresholds.Moderate || thresholds.VeryHigh <= thresholds.High {
+		return errInvalidThresholds
+	}
+	return nil
+}
+
+// Level returns the level of a count. It is LevelLow for every count if the thresholds aren't defined.
+func (thresholds Thresholds) Level(count float64) Level {
+	if !thresholds.Defined() {
+		return LevelLow
+	}
+	switch {
+	case count >= thresholds.VeryHigh:
+		return LevelVeryHigh
+	case count >= thresholds.High:
+		return LevelHigh
+	case count >= thresholds.Moderate:
+		return LevelModerate
+	}
+	return LevelLow
+}
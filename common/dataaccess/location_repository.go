@@ -0,0 +1,134 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrLocationInUse is returned when deleting a location that still has pollen data without cascading
+var ErrLocationInUse = errors.New("location still has pollen data")
+
+// ErrLocationIDTaken is returned when the next free id is taken by other new locations every time
+// CreateLocation tries it
+var ErrLocationIDTaken = errors.New("the id of the location was taken by locations created at the same time")
+
+// createLocationAttempts is the number of times CreateLocation tries the next free id
+const createLocationAttempts = 5
+
+// CreateLocation inserts a new location. The location is given the next free id, which is set on location.
+// Locations created at the same time can choose the same id, as neither Ignite nor the isolation level of
+// PostgreSQL keep them apart, so the id is chosen again when the insert fails because it was taken.
+func (repo *PollenRepository) CreateLocation(location *Location) error {
+	err := ErrLocationIDTaken
+	for attempt := 0; attempt < createLocationAttempts && err == ErrLocationIDTaken; attempt++ {
+		err = repo.insertLocation(location)
+	}
+	if err != nil {
+		log.Println(fmt.Errorf("failed insert location: %v", err))
+	}
+	return err
+}
+
+// insertLocation inserts location with the next free id. Returns ErrLocationIDTaken if another location
+// got the id first.
+func (repo *PollenRepository) insertLocation(location *Location) error {
+	id := -1
+	err := repo.withTransaction(func(exec executor) error {
+		var maxLocation sql.NullInt64
+		err := exec.QueryRow(`SELECT MAX(Location) FROM Locations`).Scan(&maxLocation)
+		if err != nil {
+			return err
+		}
+		id = 0
+		if maxLocation.Valid {
+			id = int(maxLocation.Int64) + 1
+		}
+		_, err = exec.Exec(repo.backend.rebind(`
+			INSERT INTO Locations (Location, Country, City, TimeZone, Latitude, Longitude, Elevation)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`),
+			id, location.Country, location.City, location.TimeZone,
+			location.Latitude, location.Longitude, location.Elevation)
+		return err
+	})
+	if err == nil {
+		location.Location = id
+		return nil
+	}
+
+	// The insert fails on the primary key when the id was taken in the meantime
+	var taken int
+	if repo.DB.QueryRow(repo.backend.rebind(`SELECT COUNT(*) FROM Locations WHERE Location = ?`), id).Scan(&taken) == nil && taken > 0 {
+		return ErrLocationIDTaken
+	}
+	return err
+}
+
+// UpdateLocation updates the country, city, time zone and coordinates of an existing location.
+// Returns sql.ErrNoRows if the location doesn't exist.
+func (repo *PollenRepository) UpdateLocation(location *Location) error {
+	result, err := repo.DB.Exec(repo.backend.rebind(`
+		UPDATE Locations
+		SET Country = ?, City = ?, TimeZone = ?, Latitude = ?, Longitude = ?, Elevation = ?
+		WHERE Location = ?`),
+		location.Country, location.City, location.TimeZone,
+		location.Latitude, location.Longitude, location.Elevation, location.Location)
+	if err != nil {
+		log.Println(fmt.Errorf("failed update location: %v", err))
+		return err
+	}
+	return requireAffectedRows(result)
+}
+
+// DeleteLocation deletes a location and its feed stations. A location with pollen data or forecasts is
+// only deleted if cascade is set, in which case its data is deleted too. Otherwise ErrLocationInUse is returned.
+func (repo *PollenRepository) DeleteLocation(location int, cascade bool) error {
+	err := repo.withTransaction(func(exec executor) error {
+		if !cascade {
+			var count int
+			err := exec.QueryRow(repo.backend.rebind(`
+				SELECT
+					(SELECT COUNT(*) FROM PollenArchive WHERE Location = ?) +
+					(SELECT COUNT(*) FROM PollenPredictions WHERE Location = ?)`),
+				location, location).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrLocationInUse
+			}
+		}
+		tables := []string{"FeedStations"}
+		if cascade {
+			tables = append(tables, "PollenArchive", "PollenPredictions")
+		}
+		for _, table := range tables {
+			_, err := exec.Exec(repo.backend.rebind(`DELETE FROM `+table+` WHERE Location = ?`), location)
+			if err != nil {
+				return err
+			}
+		}
+		result, err := exec.Exec(repo.backend.rebind(`DELETE FROM Locations WHERE Location = ?`), location)
+		if err != nil {
+			return err
+		}
+		return requireAffectedRows(result)
+	})
+	if err != nil && err != ErrLocationInUse && err != sql.ErrNoRows {
+		log.Println(fmt.Errorf("failed delete location: %v", err))
+	}
+	return err
+}
+
+// requireAffectedRows returns sql.ErrNoRows if a statement didn't change anything
+func requireAffectedRows(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
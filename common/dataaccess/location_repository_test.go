@@ -0,0 +1,66 @@
+package dataaccess
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// noTransactionBackend is SQLite made to run without transactions like Ignite, so concurrent statements interleave
+type noTransactionBackend struct {
+	*sqlBackend
+}
+
+func (noTransactionBackend) supportsTransactions() bool {
+	return false
+}
+
+// TestConcurrentCreateLocation creates locations from several goroutines, which choose the same ids
+func TestConcurrentCreateLocation(t *testing.T) {
+	repo := newTestRepository(t, func(sqlite backend) backend {
+		return noTransactionBackend{sqlite.(*sqlBackend)}
+	})
+	const writers, creates = 4, 10
+
+	var mutex sync.Mutex
+	ids := make(map[int]bool)
+	var wait sync.WaitGroup
+	wait.Add(writers)
+	for writer := 0; writer < writers; writer++ {
+		go func(writer int) {
+			defer wait.Done()
+			for i := 0; i < creates; i++ {
+				location := &Location{Country: "Denmark", City: fmt.Sprintf("City %d-%d", writer, i), TimeZone: "UTC"}
+				err := repo.CreateLocation(location)
+				if err == ErrLocationIDTaken {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				mutex.Lock()
+				if ids[location.Location] {
+					t.Errorf("id %d given twice", location.Location)
+				}
+				ids[location.Location] = true
+				mutex.Unlock()
+			}
+		}(writer)
+	}
+	wait.Wait()
+
+	locations, err := repo.GetAllLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copenhagen is created by the migrations
+	if len(locations) != len(ids)+1 {
+		t.Errorf("%d locations, %d created", len(locations), len(ids))
+	}
+	for _, location := range locations {
+		if location.Location != 0 && !ids[location.Location] {
+			t.Errorf("location %d, %s wasn't reported as created", location.Location, location.City)
+		}
+	}
+}
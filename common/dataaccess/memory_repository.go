@@ -0,0 +1,513 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a PollenStore keeping everything in memory. It is safe for concurrent use,
+// and is meant for tests and for running locally without Apache Ignite.
+type MemoryRepository struct {
+	mutex     sync.RWMutex
+	locations map[int]Location
+	samples   map[pollenKey]PollenSample
+	forecasts []PollenForecast
+	types     map[PollenType]PollenTypeDefinition
+	stations  map[stationKey]FeedStation
+}
+
+// stationKey identifies a feed station in the same way as the primary key of FeedStations
+type stationKey struct {
+	Location   int
+	PollenType PollenType
+}
+
+// pollenKey identifies a row in the same way as the primary key of PollenArchive
+type pollenKey struct {
+	Date       int64
+	PollenType PollenType
+	Location   int
+}
+
+func newPollenKey(date time.Time, pollenType PollenType, location int) pollenKey {
+	return pollenKey{
+		Date:       date.Unix(),
+		PollenType: pollenType,
+		Location:   location,
+	}
+}
+
+// NewMemoryRepository creates an empty in-memory repository holding the given locations and the
+// pollen types and feed stations created by the migrations
+func NewMemoryRepository(locations ...Location) *MemoryRepository {
+	repo := &MemoryRepository{
+		locations: make(map[int]Location),
+		samples:   make(map[pollenKey]PollenSample),
+		types:     make(map[PollenType]PollenTypeDefinition),
+		stations:  make(map[stationKey]FeedStation),
+	}
+	for _, location := range locations {
+		repo.locations[location.Location] = location
+	}
+	for _, pollenType := range defaultPollenTypes {
+		repo.types[pollenType.PollenType] = pollenType
+	}
+	for _, station := range defaultFeedStations {
+		repo.stations[stationKey{Location: station.Location, PollenType: station.PollenType}] = station
+	}
+	return repo
+}
+
+// GetLocation fetch a location with an id
+func (repo *MemoryRepository) GetLocation(location int) (*Location, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	result, ok := repo.locations[location]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &result, nil
+}
+
+// SearchLocation finds locations by country and/or city, best match first, and returns a page of them
+func (repo *MemoryRepository) SearchLocation(query *LocationQuery) (*LocationSearchResult, error) {
+	locations, err := repo.GetAllLocations()
+	if err != nil {
+		return nil, err
+	}
+	return searchLocations(locations, query), nil
+}
+
+// GetAllLocations fetch all locations
+func (repo *MemoryRepository) GetAllLocations() ([]*Location, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var results []*Location
+	for _, location := range repo.locations {
+		result := location
+		results = append(results, &result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Location < results[j].Location
+	})
+	return results, nil
+}
+
+// CreateLocation inserts a new location. The location is given the next free id, which is set on location.
+func (repo *MemoryRepository) CreateLocation(location *Location) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	id := 0
+	for existing := range repo.locations {
+		if existing >= id {
+			id = existing + 1
+		}
+	}
+	location.Location = id
+	repo.locations[id] = *location
+	return nil
+}
+
+// UpdateLocation updates the country, city, time zone and coordinates of an existing location.
+// Returns sql.ErrNoRows if the location doesn't exist.
+func (repo *MemoryRepository) UpdateLocation(location *Location) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	existing, ok := repo.locations[location.Location]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	existing.Country = location.Country
+	existing.City = location.City
+	existing.TimeZone = location.TimeZone
+	existing.Latitude = location.Latitude
+	existing.Longitude = location.Longitude
+	existing.Elevation = location.Elevation
+	repo.locations[location.Location] = existing
+	return nil
+}
+
+// DeleteLocation deletes a location and its feed stations. A location with pollen data or forecasts is
+// only deleted if cascade is set, in which case its data is deleted too. Otherwise ErrLocationInUse is returned.
+func (repo *MemoryRepository) DeleteLocation(location int, cascade bool) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.locations[location]; !ok {
+		return sql.ErrNoRows
+	}
+	inUse := false
+	for key := range repo.samples {
+		if key.Location == location {
+			inUse = true
+			if cascade {
+				delete(repo.samples, key)
+			}
+		}
+	}
+	var forecasts []PollenForecast
+	for _, forecast := range repo.forecasts {
+		if forecast.Location == location {
+			inUse = true
+			if cascade {
+				continue
+			}
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	if inUse && !cascade {
+		return ErrLocationInUse
+	}
+	repo.forecasts = forecasts
+	for key := range repo.stations {
+		if key.Location == location {
+			delete(repo.stations, key)
+		}
+	}
+	delete(repo.locations, location)
+	return nil
+}
+
+// GetPollenAvailability lists, for every location and pollen type with pollen data, the first and last
+// date with a measured or predicted pollen count. Ordered by location and pollen type.
+func (repo *MemoryRepository) GetPollenAvailability() ([]*PollenAvailability, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	type availabilityKey struct {
+		location   int
+		pollenType PollenType
+	}
+	found := make(map[availabilityKey]*PollenAvailability)
+	for _, sample := range repo.samples {
+		if sample.PollenCount == nil && sample.PredictedPollenCount == nil {
+			continue
+		}
+		key := availabilityKey{location: sample.Location.Location, pollenType: sample.PollenType}
+		availability, ok := found[key]
+		if !ok {
+			found[key] = &PollenAvailability{
+				Location:   key.location,
+				PollenType: key.pollenType,
+				FirstDate:  sample.Date,
+				LastDate:   sample.Date,
+			}
+			continue
+		}
+		if sample.Date.Before(availability.FirstDate) {
+			availability.FirstDate = sample.Date
+		}
+		if sample.Date.After(availability.LastDate) {
+			availability.LastDate = sample.Date
+		}
+	}
+
+	results := make([]*PollenAvailability, 0, len(found))
+	for _, availability := range found {
+		results = append(results, availability)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Location != results[j].Location {
+			return results[i].Location < results[j].Location
+		}
+		return results[i].PollenType < results[j].PollenType
+	})
+	return results, nil
+}
+
+// GetPollen fetch pollen data for a single date
+func (repo *MemoryRepository) GetPollen(date time.Time, pollenType PollenType, location int) (*PollenSample, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return repo.getPollen(newPollenKey(date, pollenType, location))
+}
+
+// getPollen looks up a sample and joins it with its location. Must be called with the lock held.
+func (repo *MemoryRepository) getPollen(key pollenKey) (*PollenSample, error) {
+	sample, ok := repo.samples[key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	// Samples without a known location are left out, like the join in the SQL queries
+	location, ok := repo.locations[key.Location]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	sample.Location = location
+	return &sample, nil
+}
+
+// GetPollenFromRange fetch pollen data for a range of dates
+func (repo *MemoryRepository) GetPollenFromRange(from time.Time, to time.Time, pollenType PollenType, location int) ([]*PollenSample, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var results []*PollenSample
+	for key := range repo.samples {
+		if key.PollenType != pollenType || key.Location != location ||
+			key.Date < from.Unix() || key.Date > to.Unix() {
+			continue
+		}
+		pollenSample, err := repo.getPollen(key)
+		if err == nil {
+			results = append(results, pollenSample)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Date.Before(results[j].Date)
+	})
+	return results, nil
+}
+
+// GetPollenFromRanges fetch pollen data for a range of dates of several pollen types and locations,
+// sorted by location, pollen type and date
+func (repo *MemoryRepository) GetPollenFromRanges(query *PollenQuery) ([]*PollenSample, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	results := []*PollenSample{}
+	for key := range repo.samples {
+		if !query.matches(time.Unix(key.Date, 0).UTC(), key.PollenType, key.Location) {
+			continue
+		}
+		pollenSample, err := repo.getPollen(key)
+		if err == nil {
+			results = append(results, pollenSample)
+		}
+	}
+	sortPollenSeries(results)
+	return results, nil
+}
+
+// GetPollenAggregates sums up the pollen data of a query per day, week, month or season
+func (repo *MemoryRepository) GetPollenAggregates(query *PollenQuery, interval Interval) ([]*PollenAggregate, error) {
+	if err := ValidateInterval(interval); err != nil {
+		return nil, err
+	}
+	samples, err := repo.GetPollenFromRanges(query)
+	if err != nil {
+		return nil, err
+	}
+	return aggregatePollen(samples, interval), nil
+}
+
+// UpsertPredictedPollenCount insert/updates the predicted pollen count for a date
+func (repo *MemoryRepository) UpsertPredictedPollenCount(pollen *PollenSample) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key := newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)
+	existing := repo.samples[key]
+	repo.store(key, pollen, existing.PollenCount, pollen.PredictedPollenCount)
+	return nil
+}
+
+// UpsertPollenCount insert/updates the actual pollen count for a date
+func (repo *MemoryRepository) UpsertPollenCount(pollen *PollenSample) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key := newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)
+	existing := repo.samples[key]
+	repo.store(key, pollen, pollen.PollenCount, existing.PredictedPollenCount)
+	return nil
+}
+
+// UpsertPollenSample insert/updates the actual pollen count and predicted pollen count for a date
+func (repo *MemoryRepository) UpsertPollenSample(pollen *PollenSample) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.storeNonNull(newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location), pollen)
+	return nil
+}
+
+// BulkUpsertPollenSamples insert/updates many pollen samples, e.g. a full history
+func (repo *MemoryRepository) BulkUpsertPollenSamples(samples []*PollenSample) (*BulkResult, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	result := &BulkResult{}
+	for _, pollen := range uniquePollenSamples(samples) {
+		key := newPollenKey(pollen.Date, pollen.PollenType, pollen.Location.Location)
+		if _, ok := repo.samples[key]; ok {
+			result.Updated++
+		} else {
+			result.Inserted++
+		}
+		repo.storeNonNull(key, pollen)
+	}
+	return result, nil
+}
+
+// store saves a sample under key. Only the location id is kept, the rest is joined on reads.
+// The counts are copied so callers can't change them afterwards. Must be called with the write lock held.
+// storeNonNull stores the counts of pollen, keeping the stored counts where pollen has none
+func (repo *MemoryRepository) storeNonNull(key pollenKey, pollen *PollenSample) {
+	existing := repo.samples[key]
+	pollenCount, predictedPollenCount := pollen.PollenCount, pollen.PredictedPollenCount
+	if pollenCount == nil {
+		pollenCount = existing.PollenCount
+	}
+	if predictedPollenCount == nil {
+		predictedPollenCount = existing.PredictedPollenCount
+	}
+	repo.store(key, pollen, pollenCount, predictedPollenCount)
+}
+
+func (repo *MemoryRepository) store(key pollenKey, pollen *PollenSample, pollenCount *int, predictedPollenCount *float32) {
+	sample := PollenSample{
+		Date:       pollen.Date,
+		PollenType: pollen.PollenType,
+		Location:   Location{Location: pollen.Location.Location},
+	}
+	if pollenCount != nil {
+		value := *pollenCount
+		sample.PollenCount = &value
+	}
+	if predictedPollenCount != nil {
+		value := *predictedPollenCount
+		sample.PredictedPollenCount = &value
+	}
+	repo.samples[key] = sample
+}
+
+// InsertPollenForecast stores a forecast. Forecasts are never overwritten, so every run is kept.
+func (repo *MemoryRepository) InsertPollenForecast(forecast *PollenForecast) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.forecasts = append(repo.forecasts, *forecast)
+	return nil
+}
+
+// GetLatestPollenForecast fetch the most recently issued forecast for a date
+func (repo *MemoryRepository) GetLatestPollenForecast(date time.Time, pollenType PollenType, location int) (*PollenForecast, error) {
+	forecasts, _ := repo.GetPollenForecasts(date, pollenType, location)
+	if len(forecasts) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return forecasts[len(forecasts)-1], nil
+}
+
+// GetPollenForecastIssuedOn fetch the forecast for a date as it was published on the day issued,
+// which is the last one issued before the end of that day in the calendar of the location
+func (repo *MemoryRepository) GetPollenForecastIssuedOn(date time.Time, pollenType PollenType, location int, issued time.Time) (*PollenForecast, error) {
+	forecastLocation, err := repo.GetLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	endOfDay := forecastLocation.EndOfDay(issued)
+	forecasts, _ := repo.GetPollenForecasts(date, pollenType, location)
+	for i := len(forecasts) - 1; i >= 0; i-- {
+		if forecasts[i].IssuedAt.Before(endOfDay) {
+			return forecasts[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetPollenForecasts fetch every forecast issued for a date, oldest first
+func (repo *MemoryRepository) GetPollenForecasts(date time.Time, pollenType PollenType, location int) ([]*PollenForecast, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var results []*PollenForecast
+	for _, forecast := range repo.forecasts {
+		if forecast.TargetDate.Equal(date) && forecast.PollenType == pollenType && forecast.Location == location {
+			result := forecast
+			results = append(results, &result)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].IssuedAt.Before(results[j].IssuedAt)
+	})
+	return results, nil
+}
+
+// GetPollenTypes returns all handled pollen types
+func (repo *MemoryRepository) GetPollenTypes() ([]*PollenTypeDefinition, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var results []*PollenTypeDefinition
+	for _, pollenType := range repo.types {
+		result := pollenType
+		results = append(results, &result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].PollenType < results[j].PollenType
+	})
+	return results, nil
+}
+
+// UpsertPollenType insert/updates a pollen type
+func (repo *MemoryRepository) UpsertPollenType(pollenType *PollenTypeDefinition) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.types[pollenType.PollenType] = *pollenType
+	return nil
+}
+
+// GetFeedStations fetch all feed station mappings
+func (repo *MemoryRepository) GetFeedStations() ([]*FeedStation, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var results []*FeedStation
+	for _, station := range repo.stations {
+		result := station
+		results = append(results, &result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Location != results[j].Location {
+			return results[i].Location < results[j].Location
+		}
+		return results[i].PollenType < results[j].PollenType
+	})
+	return results, nil
+}
+
+// GetFeedStation fetch the feed station for a location and pollen type.
+// Returns sql.ErrNoRows if the combination isn't mapped.
+func (repo *MemoryRepository) GetFeedStation(location int, pollenType PollenType) (*FeedStation, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	station, ok := repo.stations[stationKey{Location: location, PollenType: pollenType}]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &station, nil
+}
+
+// UpsertFeedStation insert/updates the feed station for a location and pollen type
+func (repo *MemoryRepository) UpsertFeedStation(station *FeedStation) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.stations[stationKey{Location: station.Location, PollenType: station.PollenType}] = *station
+	return nil
+}
+
+// DeleteFeedStation deletes the feed station for a location and pollen type.
+// Returns sql.ErrNoRows if the combination isn't mapped.
+func (repo *MemoryRepository) DeleteFeedStation(location int, pollenType PollenType) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key := stationKey{Location: location, PollenType: pollenType}
+	if _, ok := repo.stations[key]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(repo.stations, key)
+	return nil
+}
@@ -0,0 +1,399 @@
+package dataaccess
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration is a numbered change to the database schema. Up applies it and Down reverts it. Down is nil
+// for a migration that can't be reverted.
+type migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// migrations holds every schema change in the order they are applied. Never change a migration once
+// it has been released, add a new one instead.
+//
+// Ignite runs the statements of a migration without a transaction, so a failing migration is left partly
+// applied and is run again from its first statement. Every statement must therefore succeed when run
+// again, e.g. by using IF NOT EXISTS, or be the only statement of its migration, like ALTER TABLE.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "Create PollenArchive and Locations",
+		// Uses IF NOT EXISTS so databases created before migrations existed are adopted as is. Reverting it
+		// would drop the archive of those databases, so it can't be reverted.
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS PollenArchive (
+				Date TIMESTAMP,
+				PollenType INT,
+				Location INT,
+				PollenCount INT,
+				PredictedPollenCount FLOAT,
+				PRIMARY KEY (Date, PollenType, Location)
+			)`, `
+			CREATE TABLE IF NOT EXISTS Locations (
+				Location INT PRIMARY KEY,
+				Country VARCHAR,
+				City VARCHAR
+			)`, `
+			CREATE INDEX IF NOT EXISTS LocationLookup ON Locations (
+				Country ASC,
+				City ASC
+			)`, `
+			INSERT INTO Locations (Location, Country, City)
+			SELECT 0, 'Denmark', 'Copenhagen'
+			WHERE NOT EXISTS (SELECT 1 FROM Locations WHERE Location = 0)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Create PollenPredictions to keep every forecast",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS PollenPredictions (
+				TargetDate TIMESTAMP,
+				PollenType INT,
+				Location INT,
+				IssuedAt TIMESTAMP,
+				LeadDays INT,
+				PredictedPollenCount FLOAT,
+				PRIMARY KEY (TargetDate, PollenType, Location, IssuedAt, LeadDays)
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS PollenPredictions`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "Create PollenTypes with grass and birch",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS PollenTypes (
+				PollenType INT PRIMARY KEY,
+				Code VARCHAR,
+				NameEn VARCHAR,
+				NameDa VARCHAR,
+				LatinName VARCHAR,
+				PredictionName VARCHAR,
+				FeedTypeID INT
+			)`, `
+			INSERT INTO PollenTypes (PollenType, Code, NameEn, NameDa, LatinName, PredictionName, FeedTypeID)
+			SELECT 0, 'grass', 'Grass', 'Græs', 'Poaceae', 'grass', 28
+			WHERE NOT EXISTS (SELECT 1 FROM PollenTypes WHERE PollenType = 0)`, `
+			INSERT INTO PollenTypes (PollenType, Code, NameEn, NameDa, LatinName, PredictionName, FeedTypeID)
+			SELECT 1, 'birch', 'Birch', 'Birk', 'Betula', 'birch', 7
+			WHERE NOT EXISTS (SELECT 1 FROM PollenTypes WHERE PollenType = 1)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS PollenTypes`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "Create FeedStations mapping to astma-allergi.dk",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS FeedStations (
+				Location INT,
+				PollenType INT,
+				StationID INT,
+				TypeID INT,
+				PRIMARY KEY (Location, PollenType)
+			)`, `
+			INSERT INTO FeedStations (Location, PollenType, StationID, TypeID)
+			SELECT 0, 0, 48, 28
+			WHERE NOT EXISTS (SELECT 1 FROM FeedStations WHERE Location = 0 AND PollenType = 0)`, `
+			INSERT INTO FeedStations (Location, PollenType, StationID, TypeID)
+			SELECT 0, 1, 48, 7
+			WHERE NOT EXISTS (SELECT 1 FROM FeedStations WHERE Location = 0 AND PollenType = 1)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS FeedStations`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add TimeZone to Locations",
+		Up: []string{
+			`ALTER TABLE Locations ADD COLUMN TimeZone VARCHAR`,
+		},
+		Down: []string{
+			`ALTER TABLE Locations DROP COLUMN TimeZone`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "Set the time zones of Locations",
+		Up: []string{
+			`UPDATE Locations SET TimeZone = 'Europe/Copenhagen' WHERE Country = 'Denmark' AND TimeZone IS NULL`,
+			`UPDATE Locations SET TimeZone = 'UTC' WHERE TimeZone IS NULL`,
+		},
+		Down: []string{
+			`UPDATE Locations SET TimeZone = NULL`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add Latitude to Locations",
+		Up: []string{
+			`ALTER TABLE Locations ADD COLUMN Latitude FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE Locations DROP COLUMN Latitude`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add Longitude to Locations",
+		Up: []string{
+			`ALTER TABLE Locations ADD COLUMN Longitude FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE Locations DROP COLUMN Longitude`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "Add Elevation to Locations",
+		Up: []string{
+			`ALTER TABLE Locations ADD COLUMN Elevation FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE Locations DROP COLUMN Elevation`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "Set the coordinates of Copenhagen",
+		Up: []string{
+			`UPDATE Locations SET Latitude = 55.6761, Longitude = 12.5683
+			WHERE Country = 'Denmark' AND City = 'Copenhagen'`,
+		},
+		Down: []string{
+			`UPDATE Locations SET Latitude = NULL, Longitude = NULL
+			WHERE Country = 'Denmark' AND City = 'Copenhagen'`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "Add ModerateThreshold to PollenTypes",
+		Up: []string{
+			`ALTER TABLE PollenTypes ADD COLUMN ModerateThreshold FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE PollenTypes DROP COLUMN ModerateThreshold`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "Add HighThreshold to PollenTypes",
+		Up: []string{
+			`ALTER TABLE PollenTypes ADD COLUMN HighThreshold FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE PollenTypes DROP COLUMN HighThreshold`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "Add VeryHighThreshold to PollenTypes",
+		Up: []string{
+			`ALTER TABLE PollenTypes ADD COLUMN VeryHighThreshold FLOAT`,
+		},
+		Down: []string{
+			`ALTER TABLE PollenTypes DROP COLUMN VeryHighThreshold`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "Set the level thresholds of grass and birch",
+		Up: []string{
+			`UPDATE PollenTypes SET ModerateThreshold = 10, HighThreshold = 50, VeryHighThreshold = 150
+			WHERE Code = 'grass'`,
+			`UPDATE PollenTypes SET ModerateThreshold = 30, HighThreshold = 100, VeryHighThreshold = 300
+			WHERE Code = 'birch'`,
+		},
+		Down: []string{
+			`UPDATE PollenTypes SET ModerateThreshold = NULL, HighThreshold = NULL, VeryHighThreshold = NULL`,
+		},
+	},
+}
+
+// MigrationStatus tells if a migration has been applied to the database
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// ErrUnknownSchema is returned when the database has a schema version newer than the ones known
+// by this build. Running against it could corrupt data, so it is refused.
+type ErrUnknownSchema struct {
+	Version int
+	Latest  int
+}
+
+func (err *ErrUnknownSchema) Error() string {
+	return fmt.Sprintf("database schema version %v is newer than the latest known version %v", err.Version, err.Latest)
+}
+
+// LatestSchemaVersion is the schema version the code is written for
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// initSchemaVersion creates the table keeping track of applied migrations
+func (repo *PollenRepository) initSchemaVersion() error {
+	_, err := repo.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS SchemaVersion (
+			Version INT PRIMARY KEY,
+			Description VARCHAR,
+			AppliedAt TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("Failed to create SchemaVersion: %v", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the latest migration applied to the database. 0 means none.
+func (repo *PollenRepository) SchemaVersion() (int, error) {
+	if err := repo.initSchemaVersion(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err := repo.DB.QueryRow(`SELECT MAX(Version) FROM SchemaVersion`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// checkSchemaVersion returns the current schema version, or ErrUnknownSchema if it is from the future
+func (repo *PollenRepository) checkSchemaVersion() (int, error) {
+	version, err := repo.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return version, &ErrUnknownSchema{Version: version, Latest: LatestSchemaVersion()}
+	}
+	return version, nil
+}
+
+// MigrationStatus lists all known migrations and whether they have been applied
+func (repo *PollenRepository) MigrationStatus() ([]*MigrationStatus, error) {
+	if err := repo.initSchemaVersion(); err != nil {
+		return nil, err
+	}
+	rows, err := repo.DB.Query(`SELECT Version, AppliedAt FROM SchemaVersion`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]*MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		results[i] = &MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     ok,
+			AppliedAt:   appliedAt,
+		}
+	}
+	return results, nil
+}
+
+// MigrateUp applies all pending migrations up to and including target. A target of 0 means the latest.
+// A target below the schema version or above the latest migration is an error.
+func (repo *PollenRepository) MigrateUp(target int) error {
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+	version, err := repo.checkSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if target < version || target > LatestSchemaVersion() {
+		return fmt.Errorf("Can't migrate up from version %v to %v, the latest version is %v",
+			version, target, LatestSchemaVersion())
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+		log.Printf("Applying migration %v: %s", migration.Version, migration.Description)
+		err := repo.applyMigration(migration.Up, func(exec executor) error {
+			_, err := exec.Exec(repo.backend.rebind(`
+				INSERT INTO SchemaVersion (Version, Description, AppliedAt) VALUES (?, ?, ?)`),
+				migration.Version, migration.Description, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to apply migration %v: %v", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts applied migrations until the schema is at version target. A target above the schema
+// version is an error. Nothing is reverted if one of the migrations can't be.
+func (repo *PollenRepository) MigrateDown(target int) error {
+	version, err := repo.checkSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if target > version || target < 0 {
+		return fmt.Errorf("Can't migrate down from version %v to %v", version, target)
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version && migration.Version > target && migration.Down == nil {
+			return fmt.Errorf("Migration %v can't be reverted", migration.Version)
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version > version || migration.Version <= target {
+			continue
+		}
+		log.Printf("Reverting migration %v: %s", migration.Version, migration.Description)
+		err := repo.applyMigration(migration.Down, func(exec executor) error {
+			_, err := exec.Exec(repo.backend.rebind(`DELETE FROM SchemaVersion WHERE Version = ?`), migration.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to revert migration %v: %v", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs statements followed by record, in a transaction if the backend supports it
+func (repo *PollenRepository) applyMigration(statements []string, record func(exec executor) error) error {
+	return repo.withTransaction(func(exec executor) error {
+		for _, statement := range statements {
+			if _, err := exec.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return record(exec)
+	})
+}
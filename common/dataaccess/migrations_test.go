@@ -0,0 +1,47 @@
+package dataaccess
+
+import "testing"
+
+func TestMigrationTargets(t *testing.T) {
+	repo := newTestRepository(t, nil)
+	latest := LatestSchemaVersion()
+	if err := repo.MigrateDown(latest - 2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		migrate func() error
+	}{
+		{"up below the version", func() error { return repo.MigrateUp(latest - 3) }},
+		{"up above the latest", func() error { return repo.MigrateUp(latest + 1) }},
+		{"down above the version", func() error { return repo.MigrateDown(latest - 1) }},
+		{"down past migration 1", func() error { return repo.MigrateDown(0) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.migrate(); err == nil {
+				t.Error("no error")
+			}
+			if version, err := repo.SchemaVersion(); err != nil || version != latest-2 {
+				t.Errorf("version %v, want %v: %v", version, latest-2, err)
+			}
+		})
+	}
+}
+
+// TestMigrationsCanBeRunAgain runs the statements of every migration with more than one statement again,
+// as Ignite does when such a migration failed halfway
+func TestMigrationsCanBeRunAgain(t *testing.T) {
+	repo := newTestRepository(t, nil)
+	for _, migration := range migrations {
+		if len(migration.Up) < 2 {
+			continue
+		}
+		for _, statement := range migration.Up {
+			if _, err := repo.DB.Exec(statement); err != nil {
+				t.Errorf("migration %v: %v", migration.Version, err)
+			}
+		}
+	}
+}
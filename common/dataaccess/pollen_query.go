@@ -0,0 +1,149 @@
+package dataaccess
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// PollenQuery selects the pollen data of a range of dates for several pollen types and locations at once
+type PollenQuery struct {
+	From time.Time
+	To   time.Time
+	// PollenTypes and Locations list the pollen types and locations to find. Empty means all of them.
+	PollenTypes []PollenType
+	Locations   []int
+}
+
+// matches tells if the pollen data of a date, pollen type and location is selected by the query
+func (query *PollenQuery) matches(date time.Time, pollenType PollenType, location int) bool {
+	if date.Before(query.From) || date.After(query.To) {
+		return false
+	}
+	if len(query.PollenTypes) > 0 && !containsPollenType(query.PollenTypes, pollenType) {
+		return false
+	}
+	if len(query.Locations) > 0 && !containsInt(query.Locations, location) {
+		return false
+	}
+	return true
+}
+
+// sql returns the query of GetPollenFromRanges and its arguments. The lists of pollen types and locations
+// vary in length, so it can't be prepared once like the other statements.
+func (query *PollenQuery) sql() (string, []interface{}) {
+	builder := strings.Builder{}
+	builder.WriteString(`
+		SELECT 
+			Date,
+			PollenType,
+			Locations.Location,
+			Locations.Country,
+			Locations.City,
+			Locations.TimeZone,
+			Locations.Latitude,
+			Locations.Longitude,
+			Locations.Elevation,
+			PollenCount, 
+			PredictedPollenCount 
+		FROM PollenArchive 
+		JOIN Locations on PollenArchive.Location = Locations.Location`)
+	args := query.writeConditions(&builder)
+	builder.WriteString(`
+		ORDER BY PollenArchive.Location, PollenType, Date`)
+	return builder.String(), args
+}
+
+// aggregateSQL returns the query of GetPollenAggregates and its arguments, grouping by the buckets of the
+// expression bucket. Buckets are sorted like the samples of GetPollenFromRanges.
+func (query *PollenQuery) aggregateSQL(bucket string) (string, []interface{}) {
+	builder := strings.Builder{}
+	builder.WriteString(`
+		SELECT 
+			` + bucket + ` AS Bucket,
+			PollenType,
+			Locations.Location,
+			Locations.Country,
+			Locations.City,
+			Locations.TimeZone,
+			Locations.Latitude,
+			Locations.Longitude,
+			Locations.Elevation,
+			COUNT(PollenCount),
+			SUM(PollenCount),
+			MAX(PollenCount),
+			COUNT(PredictedPollenCount),
+			SUM(PredictedPollenCount)
+		FROM PollenArchive 
+		JOIN Locations on PollenArchive.Location = Locations.Location`)
+	args := query.writeConditions(&builder)
+	builder.WriteString(`
+		GROUP BY 
+			` + bucket + `,
+			PollenType,
+			Locations.Location,
+			Locations.Country,
+			Locations.City,
+			Locations.TimeZone,
+			Locations.Latitude,
+			Locations.Longitude,
+			Locations.Elevation
+		ORDER BY Locations.Location, PollenType, Bucket`)
+	return builder.String(), args
+}
+
+// writeConditions writes the WHERE clause selecting the rows of PollenArchive of the query, and returns its arguments
+func (query *PollenQuery) writeConditions(builder *strings.Builder) []interface{} {
+	builder.WriteString(`
+		WHERE 
+			Date >= ? AND 
+			Date <= ?`)
+	args := []interface{}{query.From, query.To}
+	if len(query.PollenTypes) > 0 {
+		builder.WriteString(` AND 
+			PollenType IN (` + placeholders(len(query.PollenTypes)) + `)`)
+		for _, pollenType := range query.PollenTypes {
+			args = append(args, int(pollenType))
+		}
+	}
+	if len(query.Locations) > 0 {
+		builder.WriteString(` AND 
+			PollenArchive.Location IN (` + placeholders(len(query.Locations)) + `)`)
+		for _, location := range query.Locations {
+			args = append(args, location)
+		}
+	}
+	return args
+}
+
+func containsPollenType(pollenTypes []PollenType, pollenType PollenType) bool {
+	for _, candidate := range pollenTypes {
+		if candidate == pollenType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortPollenSeries sorts samples by location, pollen type and date
+func sortPollenSeries(samples []*PollenSample) {
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		if a.Location.Location != b.Location.Location {
+			return a.Location.Location < b.Location.Location
+		}
+		if a.PollenType != b.PollenType {
+			return a.PollenType < b.PollenType
+		}
+		return a.Date.Before(b.Date)
+	})
+}
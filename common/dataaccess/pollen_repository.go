@@ -4,37 +4,28 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
-
-	"github.com/amsokol/ignite-go-client/binary/v1"
-	// Here to import the sql driver
-	_ "github.com/amsokol/ignite-go-client/sql"
 )
 
-// GetConnection initializes a new db connection
+// GetConnection initializes a new db connection using the backend selected in db.toml
 func GetConnection() (*PollenRepository, error) {
 	config := getConfig()
-	// connect
-	db, err := sql.Open("ignite", config.SQLConnectionString)
+	backend, err := getBackend(config.Driver)
 	if err != nil {
 		log.Fatalf("failed connect to db: %v", err)
 		return nil, err
 	}
 
-	repo := &PollenRepository{
-		DB: db,
-	}
-
-	client, err := ignite.Connect(config.ConnInfo)
+	db, err := backend.connect(config)
 	if err != nil {
-		log.Fatalf("failed connect to server: %v", err)
-		return repo, err
+		log.Fatalf("failed connect to db: %v", err)
+		return nil, err
 	}
-	defer client.Close()
-	err = client.CacheGetOrCreateWithName(config.CacheName)
-	if err != nil {
-		log.Fatalf("failed to get or create cache: %v", err)
-		return repo, err
+
+	repo := &PollenRepository{
+		DB:      db,
+		backend: backend,
 	}
 	return repo, nil
 }
@@ -43,6 +34,7 @@ func GetConnection() (*PollenRepository, error) {
 type PollenRepository struct {
 	DB                 *sql.DB
 	PreparedStatements map[string]*sql.Stmt
+	backend            backend
 }
 
 // Scanner is an interface implemented by both sql.Row and sql.Rows.
@@ -52,10 +44,24 @@ type Scanner interface {
 
 func rowToLocation(row Scanner) (*Location, error) {
 	location := &Location{}
-	err := row.Scan(&location.Location, &location.Country, &location.City)
+	var timeZone sql.NullString
+	var latitude, longitude, elevation sql.NullFloat64
+	err := row.Scan(&location.Location, &location.Country, &location.City, &timeZone,
+		&latitude, &longitude, &elevation)
+	location.TimeZone = timeZone.String
+	location.Latitude = nullFloatToPointer(latitude)
+	location.Longitude = nullFloatToPointer(longitude)
+	location.Elevation = nullFloatToPointer(elevation)
 	return location, err
 }
 
+func nullFloatToPointer(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
 func rowToPollenSample(row Scanner) (*PollenSample, error) {
 	pollenSampleSQL := &pollenSampleSQL{}
 	err := row.Scan(&pollenSampleSQL.Date,
@@ -63,6 +69,10 @@ func rowToPollenSample(row Scanner) (*PollenSample, error) {
 		&pollenSampleSQL.Location.Location,
 		&pollenSampleSQL.Location.Country,
 		&pollenSampleSQL.Location.City,
+		&pollenSampleSQL.TimeZone,
+		&pollenSampleSQL.Latitude,
+		&pollenSampleSQL.Longitude,
+		&pollenSampleSQL.Elevation,
 		&pollenSampleSQL.PollenCount,
 		&pollenSampleSQL.PredictedPollenCount)
 	if err != nil {
@@ -73,46 +83,111 @@ func rowToPollenSample(row Scanner) (*PollenSample, error) {
 		PollenType: pollenSampleSQL.PollenType,
 		Location:   pollenSampleSQL.Location,
 	}
+	pollenSample.Location.TimeZone = pollenSampleSQL.TimeZone.String
+	pollenSample.Location.Latitude = nullFloatToPointer(pollenSampleSQL.Latitude)
+	pollenSample.Location.Longitude = nullFloatToPointer(pollenSampleSQL.Longitude)
+	pollenSample.Location.Elevation = nullFloatToPointer(pollenSampleSQL.Elevation)
 	if pollenSampleSQL.PollenCount.Valid {
-		pollenSample.PollenCount = int(pollenSampleSQL.PollenCount.Int64)
+		pollenCount := int(pollenSampleSQL.PollenCount.Int64)
+		pollenSample.PollenCount = &pollenCount
 	}
 	if pollenSampleSQL.PredictedPollenCount.Valid {
-		pollenSample.PredictedPollenCount = float32(pollenSampleSQL.PredictedPollenCount.Float64)
+		predictedPollenCount := float32(pollenSampleSQL.PredictedPollenCount.Float64)
+		pollenSample.PredictedPollenCount = &predictedPollenCount
 	}
 	return pollenSample, err
 }
 
-// InitDb Initializes database structure if it doesn't exist
-func (repo *PollenRepository) InitDb() {
-	var err error
-	_, err = repo.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS PollenArchive (
-			Date TIMESTAMP,
-			PollenType INT,
-			Location INT,
-			PollenCount INT, 
-			PredictedPollenCount FLOAT,
-			PRIMARY KEY (Date, PollenType, Location)
-		)`)
+func rowToPollenAggregate(row Scanner, interval Interval) (*PollenAggregate, error) {
+	aggregate := &PollenAggregate{}
+	var bucket string
+	var timeZone sql.NullString
+	var latitude, longitude, elevation sql.NullFloat64
+	var sum, max sql.NullInt64
+	var predictedSum sql.NullFloat64
+	err := row.Scan(&bucket,
+		&aggregate.PollenType,
+		&aggregate.Location.Location,
+		&aggregate.Location.Country,
+		&aggregate.Location.City,
+		&timeZone,
+		&latitude,
+		&longitude,
+		&elevation,
+		&aggregate.MeasuredDays,
+		&sum,
+		&max,
+		&aggregate.PredictedDays,
+		&predictedSum)
 	if err != nil {
-		panic(fmt.Errorf("Failed to create PollenArchive: %v", err))
-	}
-	_, err = repo.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS Locations (
-			Location INT PRIMARY KEY,
-			Country VARCHAR,
-			City VARCHAR
-		)`)
+		return nil, err
+	}
+	aggregate.Start, err = time.Parse("2006-01-02", bucket)
 	if err != nil {
-		panic(fmt.Errorf("Failed to create Locations: %v", err))
+		return nil, err
+	}
+	aggregate.End = interval.BucketEnd(aggregate.Start)
+	aggregate.Location.TimeZone = timeZone.String
+	aggregate.Location.Latitude = nullFloatToPointer(latitude)
+	aggregate.Location.Longitude = nullFloatToPointer(longitude)
+	aggregate.Location.Elevation = nullFloatToPointer(elevation)
+	aggregate.Sum = int(sum.Int64)
+	if max.Valid {
+		maxCount := int(max.Int64)
+		aggregate.Max = &maxCount
 	}
-	_, err = repo.DB.Exec(`
-		CREATE INDEX IF NOT EXISTS LocationLookup ON Locations (
-			Country ASC,
-			City ASC
-		)`)
+	finishAggregate(aggregate, predictedSum.Float64)
+	return aggregate, nil
+}
+
+func rowToPollenForecast(row Scanner) (*PollenForecast, error) {
+	forecast := &PollenForecast{}
+	err := row.Scan(&forecast.TargetDate,
+		&forecast.PollenType,
+		&forecast.Location,
+		&forecast.IssuedAt,
+		&forecast.LeadDays,
+		&forecast.PredictedPollenCount)
 	if err != nil {
-		panic(fmt.Errorf("Failed to create index on Locations: %v", err))
+		return nil, err
+	}
+	return forecast, nil
+}
+
+func rowToPollenTypeDefinition(row Scanner) (*PollenTypeDefinition, error) {
+	pollenType := &PollenTypeDefinition{}
+	var code, nameEn, nameDa, latinName, predictionName sql.NullString
+	var feedTypeID sql.NullInt64
+	var moderate, high, veryHigh sql.NullFloat64
+	err := row.Scan(&pollenType.PollenType,
+		&code,
+		&nameEn,
+		&nameDa,
+		&latinName,
+		&predictionName,
+		&feedTypeID,
+		&moderate,
+		&high,
+		&veryHigh)
+	if err != nil {
+		return nil, err
+	}
+	pollenType.Code = code.String
+	pollenType.NameEn = nameEn.String
+	pollenType.NameDa = nameDa.String
+	pollenType.LatinName = latinName.String
+	pollenType.PredictionName = predictionName.String
+	pollenType.FeedTypeID = int(feedTypeID.Int64)
+	pollenType.Thresholds = Thresholds{Moderate: moderate.Float64, High: high.Float64, VeryHigh: veryHigh.Float64}
+	return pollenType, nil
+}
+
+// InitDb migrates the database to the latest schema and prepares the statements used by the repository.
+// It refuses to run against a schema newer than the code knows about.
+func (repo *PollenRepository) InitDb() {
+	err := repo.MigrateUp(0)
+	if err != nil {
+		panic(fmt.Errorf("Failed to migrate database: %v", err))
 	}
 
 	repo.PreparedStatements = make(map[string]*sql.Stmt)
@@ -120,7 +195,11 @@ func (repo *PollenRepository) InitDb() {
 		SELECT 
 			Location,
 			Country,
-			City
+			City,
+			TimeZone,
+			Latitude,
+			Longitude,
+			Elevation
 		FROM Locations
 		WHERE 
 			Location = ?`)
@@ -128,17 +207,12 @@ func (repo *PollenRepository) InitDb() {
 		SELECT 
 			Location,
 			Country,
-			City
+			City,
+			TimeZone,
+			Latitude,
+			Longitude,
+			Elevation
 		FROM Locations`)
-	repo.prepareStatement("SearchLocation", `
-		SELECT 
-			Location,
-			Country,
-			City
-		FROM Locations
-		WHERE 
-			Country = ? AND
-			City = ?`)
 	repo.prepareStatement("FetchPollen", `
 		SELECT 
 			Date,
@@ -146,6 +220,10 @@ func (repo *PollenRepository) InitDb() {
 			Locations.Location,
 			Locations.Country,
 			Locations.City,
+			Locations.TimeZone,
+			Locations.Latitude,
+			Locations.Longitude,
+			Locations.Elevation,
 			PollenCount, 
 			PredictedPollenCount 
 		FROM PollenArchive 
@@ -161,6 +239,10 @@ func (repo *PollenRepository) InitDb() {
 			Locations.Location,
 			Locations.Country,
 			Locations.City,
+			Locations.TimeZone,
+			Locations.Latitude,
+			Locations.Longitude,
+			Locations.Elevation,
 			PollenCount, 
 			PredictedPollenCount 
 		FROM PollenArchive 
@@ -170,10 +252,99 @@ func (repo *PollenRepository) InitDb() {
 			Date <= ? AND 
 			PollenType = ? AND 
 			PollenArchive.Location = ?`)
+	repo.prepareStatement("FetchPollenAvailability", `
+		SELECT
+			Location,
+			PollenType,
+			MIN(Date),
+			MAX(Date)
+		FROM PollenArchive
+		WHERE
+			PollenCount IS NOT NULL OR
+			PredictedPollenCount IS NOT NULL
+		GROUP BY Location, PollenType
+		ORDER BY Location, PollenType`)
+	repo.prepareStatement("FetchPollenTypes", `
+		SELECT 
+			PollenType,
+			Code,
+			NameEn,
+			NameDa,
+			LatinName,
+			PredictionName,
+			FeedTypeID,
+			ModerateThreshold,
+			HighThreshold,
+			VeryHighThreshold
+		FROM PollenTypes
+		ORDER BY PollenType`)
+	repo.prepareStatement("FetchFeedStations", `
+		SELECT 
+			Location,
+			PollenType,
+			StationID,
+			TypeID
+		FROM FeedStations
+		ORDER BY Location, PollenType`)
+	repo.prepareStatement("FetchFeedStation", `
+		SELECT 
+			Location,
+			PollenType,
+			StationID,
+			TypeID
+		FROM FeedStations
+		WHERE 
+			Location = ? AND 
+			PollenType = ?`)
+	repo.prepareStatement("FetchLatestForecast", `
+		SELECT 
+			TargetDate,
+			PollenType,
+			Location,
+			IssuedAt,
+			LeadDays,
+			PredictedPollenCount
+		FROM PollenPredictions
+		WHERE 
+			TargetDate = ? AND 
+			PollenType = ? AND 
+			Location = ?
+		ORDER BY IssuedAt DESC
+		LIMIT 1`)
+	repo.prepareStatement("FetchForecastIssuedBefore", `
+		SELECT 
+			TargetDate,
+			PollenType,
+			Location,
+			IssuedAt,
+			LeadDays,
+			PredictedPollenCount
+		FROM PollenPredictions
+		WHERE 
+			TargetDate = ? AND 
+			PollenType = ? AND 
+			Location = ? AND 
+			IssuedAt < ?
+		ORDER BY IssuedAt DESC
+		LIMIT 1`)
+	repo.prepareStatement("FetchForecasts", `
+		SELECT 
+			TargetDate,
+			PollenType,
+			Location,
+			IssuedAt,
+			LeadDays,
+			PredictedPollenCount
+		FROM PollenPredictions
+		WHERE 
+			TargetDate = ? AND 
+			PollenType = ? AND 
+			Location = ?
+		ORDER BY IssuedAt ASC`)
 }
 
 func (repo *PollenRepository) prepareStatement(key string, statement string) {
-	query, err := repo.DB.Prepare(statement)
+	query, err := repo.DB.Prepare(repo.backend.rebind(statement))
 	if err != nil {
 		log.Println(fmt.Errorf("failed prepare query: %v", err))
 	} else {
@@ -192,12 +363,13 @@ func (repo *PollenRepository) GetLocation(location int) (*Location, error) {
 	return rowToLocation(row)
 }
 
-// SearchLocation find location with given country and city
-func (repo *PollenRepository) SearchLocation(country string, city string) (*Location, error) {
-	// TODO: allow to search by only city or country
-	// TODO: upper/lower case handling
-	row := repo.PreparedStatements["SearchLocation"].QueryRow(country, city)
-	return rowToLocation(row)
+// SearchLocation finds locations by country and/or city, best match first, and returns a page of them
+func (repo *PollenRepository) SearchLocation(query *LocationQuery) (*LocationSearchResult, error) {
+	locations, err := repo.GetAllLocations()
+	if err != nil {
+		return nil, err
+	}
+	return searchLocations(locations, query), nil
 }
 
 // GetAllLocations fetch all locations
@@ -254,59 +426,264 @@ func (repo *PollenRepository) GetPollenFromRange(from time.Time, to time.Time, p
 	return results, err
 }
 
-// UpsertPredictedPollenCount insert/updates the actual pollen count for a date
-func (repo *PollenRepository) UpsertPredictedPollenCount(pollen *PollenSample) error {
-	existing, err := repo.GetPollen(pollen.Date, pollen.PollenType, pollen.Location.Location)
+// GetPollenFromRanges fetch pollen data for a range of dates of several pollen types and locations in a
+// single query, sorted by location, pollen type and date
+func (repo *PollenRepository) GetPollenFromRanges(query *PollenQuery) ([]*PollenSample, error) {
+	statement, args := query.sql()
+	results := []*PollenSample{}
+	rows, err := repo.DB.Query(repo.backend.rebind(statement), args...)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		pollenSample, err := rowToPollenSample(rows)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+		} else {
+			results = append(results, pollenSample)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+	}
+	return results, err
+}
+
+// GetPollenAggregates sums up the pollen data of a query per day, week, month or season. The database groups
+// the rows by bucket, except on Ignite, where the rows are summed up in Go.
+func (repo *PollenRepository) GetPollenAggregates(query *PollenQuery, interval Interval) ([]*PollenAggregate, error) {
+	if err := ValidateInterval(interval); err != nil {
+		return nil, err
+	}
+	bucket := repo.backend.bucketStart(interval, "Date")
+	if bucket == "" {
+		samples, err := repo.GetPollenFromRanges(query)
+		if err != nil {
+			return nil, err
+		}
+		return aggregatePollen(samples, interval), nil
+	}
+
+	statement, args := query.aggregateSQL(bucket)
+	results := []*PollenAggregate{}
+	rows, err := repo.DB.Query(repo.backend.rebind(statement), args...)
 	if err != nil {
-		existing = &PollenSample{}
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		aggregate, err := rowToPollenAggregate(rows, interval)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+			return nil, err
+		}
+		results = append(results, aggregate)
 	}
-	_, err = repo.DB.Exec(`
-		MERGE INTO PollenArchive (Date, PollenType, Location, PollenCount, PredictedPollenCount) 
-		VALUES (?, ?, ?, ?, ?)`,
-		pollen.Date, int(pollen.PollenType), pollen.Location.Location, existing.PollenCount, pollen.PredictedPollenCount)
+	err = rows.Err()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+	}
+	return results, err
+}
+
+// UpsertPredictedPollenCount insert/updates the predicted pollen count for a date.
+// The pollen count is never touched, so it is safe to run concurrently with UpsertPollenCount.
+func (repo *PollenRepository) UpsertPredictedPollenCount(pollen *PollenSample) error {
+	err := repo.upsertColumns(repo.DB, "PollenArchive",
+		[]string{"Date", "PollenType", "Location"},
+		[]interface{}{pollen.Date, int(pollen.PollenType), pollen.Location.Location},
+		[]string{"PredictedPollenCount"},
+		[]interface{}{pollen.PredictedPollenCount})
 	if err != nil {
 		log.Println(fmt.Errorf("failed insert data: %v", err))
 	}
 	return err
 }
 
-// UpsertPollenCount insert/updates the actual pollen count for a date
+// UpsertPollenCount insert/updates the actual pollen count for a date.
+// The predicted pollen count is never touched, so it is safe to run concurrently with UpsertPredictedPollenCount.
 func (repo *PollenRepository) UpsertPollenCount(pollen *PollenSample) error {
-	existing, err := repo.GetPollen(pollen.Date, pollen.PollenType, pollen.Location.Location)
+	err := repo.upsertColumns(repo.DB, "PollenArchive",
+		[]string{"Date", "PollenType", "Location"},
+		[]interface{}{pollen.Date, int(pollen.PollenType), pollen.Location.Location},
+		[]string{"PollenCount"},
+		[]interface{}{pollen.PollenCount})
 	if err != nil {
-		existing = &PollenSample{}
+		log.Println(fmt.Errorf("failed insert data: %v", err))
+	}
+	return err
+}
+
+// upsertColumns sets valueColumns of the row identified by keyColumns, inserting the row if it doesn't
+// exist. Other columns of an existing row are left as they are, also when writers race.
+func (repo *PollenRepository) upsertColumns(exec executor, table string, keyColumns []string, keys []interface{}, valueColumns []string, values []interface{}) error {
+	args := append(append([]interface{}{}, keys...), values...)
+	if repo.backend.upsertKeepsOtherColumns() {
+		_, err := exec.Exec(repo.backend.rebind(repo.backend.upsertQuery(table, keyColumns, valueColumns, 1)), args...)
+		return err
+	}
+
+	// Update only the given columns. If there is no row, insert it. If another writer inserted the row
+	// in the meantime the insert fails on the primary key, and the update is tried again.
+	assignments := make([]string, len(valueColumns))
+	for i, column := range valueColumns {
+		assignments[i] = column + " = ?"
+	}
+	conditions := make([]string, len(keyColumns))
+	for i, column := range keyColumns {
+		conditions[i] = column + " = ?"
+	}
+	update := repo.backend.rebind(fmt.Sprintf(`UPDATE %s SET %s WHERE %s`,
+		table, strings.Join(assignments, ", "), strings.Join(conditions, " AND ")))
+	updateArgs := append(append([]interface{}{}, values...), keys...)
+	insert := repo.backend.rebind(fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		table, strings.Join(append(append([]string{}, keyColumns...), valueColumns...), ", "), placeholders(len(args))))
+
+	result, err := exec.Exec(update, updateArgs...)
+	if err != nil {
+		return err
+	}
+	if requireAffectedRows(result) == nil {
+		return nil
+	}
+	_, insertErr := exec.Exec(insert, args...)
+	if insertErr == nil {
+		return nil
+	}
+	result, err = exec.Exec(update, updateArgs...)
+	if err != nil {
+		return err
+	}
+	if requireAffectedRows(result) != nil {
+		return insertErr
+	}
+	return nil
+}
+
+// UpsertPollenSample insert/updates the actual pollen count and predicted pollen count for a date.
+// Unknown counts don't overwrite the counts of an existing row.
+func (repo *PollenRepository) UpsertPollenSample(pollen *PollenSample) error {
+	samples := []*PollenSample{pollen}
+	existing, err := repo.existingKeys(repo.DB, samples)
+	if err == nil {
+		err = repo.upsertPollenSamples(repo.DB, samples, existing)
 	}
-	_, err = repo.DB.Exec(`
-		MERGE INTO PollenArchive (Date, PollenType, Location, PollenCount, PredictedPollenCount) 
-		VALUES (?, ?, ?, ?, ?)`,
-		pollen.Date, int(pollen.PollenType), pollen.Location.Location, pollen.PollenCount, existing.PredictedPollenCount)
 	if err != nil {
 		log.Println(fmt.Errorf("failed insert data: %v", err))
 	}
 	return err
 }
 
-// UpsertPollenSample insert/updates the actual pollen count and predicted pollen count for a date
-func (repo *PollenRepository) UpsertPollenSample(pollen *PollenSample) error {
-	_, err := repo.DB.Exec(`
-		MERGE INTO PollenArchive (Date, PollenType, Location, PollenCount, PredictedPollenCount) 
-		(SELECT ?, ?, ?, ?, ?)`,
-		pollen.Date, int(pollen.PollenType), pollen.Location.Location, pollen.PollenCount, pollen.PredictedPollenCount)
+// InsertPollenForecast stores a forecast. Forecasts are never overwritten, so every run is kept.
+func (repo *PollenRepository) InsertPollenForecast(forecast *PollenForecast) error {
+	_, err := repo.DB.Exec(repo.backend.rebind(`
+		INSERT INTO PollenPredictions (TargetDate, PollenType, Location, IssuedAt, LeadDays, PredictedPollenCount) 
+		VALUES (?, ?, ?, ?, ?, ?)`),
+		forecast.TargetDate, int(forecast.PollenType), forecast.Location,
+		forecast.IssuedAt, forecast.LeadDays, forecast.PredictedPollenCount)
+	if err != nil {
+		log.Println(fmt.Errorf("failed insert data: %v", err))
+	}
+	return err
+}
+
+// GetLatestPollenForecast fetch the most recently issued forecast for a date
+func (repo *PollenRepository) GetLatestPollenForecast(date time.Time, pollenType PollenType, location int) (*PollenForecast, error) {
+	row := repo.PreparedStatements["FetchLatestForecast"].QueryRow(date, int(pollenType), location)
+	return rowToPollenForecast(row)
+}
+
+// GetPollenForecastIssuedOn fetch the forecast for a date as it was published on the day issued,
+// which is the last one issued before the end of that day in the calendar of the location
+func (repo *PollenRepository) GetPollenForecastIssuedOn(date time.Time, pollenType PollenType, location int, issued time.Time) (*PollenForecast, error) {
+	forecastLocation, err := repo.GetLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	endOfDay := forecastLocation.EndOfDay(issued)
+	row := repo.PreparedStatements["FetchForecastIssuedBefore"].QueryRow(date, int(pollenType), location, endOfDay)
+	return rowToPollenForecast(row)
+}
+
+// GetPollenForecasts fetch every forecast issued for a date, oldest first
+func (repo *PollenRepository) GetPollenForecasts(date time.Time, pollenType PollenType, location int) ([]*PollenForecast, error) {
+	var results []*PollenForecast
+	rows, err := repo.PreparedStatements["FetchForecasts"].Query(date, int(pollenType), location)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		forecast, err := rowToPollenForecast(rows)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+		} else {
+			results = append(results, forecast)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+	}
+	return results, err
+}
+
+// GetPollenTypes returns all handled pollen types
+func (repo *PollenRepository) GetPollenTypes() ([]*PollenTypeDefinition, error) {
+	var results []*PollenTypeDefinition
+	rows, err := repo.PreparedStatements["FetchPollenTypes"].Query()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		pollenType, err := rowToPollenTypeDefinition(rows)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to get data: %v", err))
+		} else {
+			results = append(results, pollenType)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get data: %v", err))
+	}
+	return results, err
+}
+
+// UpsertPollenType insert/updates a pollen type
+func (repo *PollenRepository) UpsertPollenType(pollenType *PollenTypeDefinition) error {
+	_, err := repo.DB.Exec(repo.backend.rebind(repo.backend.upsertQuery("PollenTypes",
+		[]string{"PollenType"},
+		[]string{"Code", "NameEn", "NameDa", "LatinName", "PredictionName", "FeedTypeID",
+			"ModerateThreshold", "HighThreshold", "VeryHighThreshold"}, 1)),
+		int(pollenType.PollenType), pollenType.Code, pollenType.NameEn, pollenType.NameDa,
+		pollenType.LatinName, pollenType.PredictionName, pollenType.FeedTypeID,
+		pollenType.Thresholds.Moderate, pollenType.Thresholds.High, pollenType.Thresholds.VeryHigh)
 	if err != nil {
 		log.Println(fmt.Errorf("failed insert data: %v", err))
 	}
 	return err
 }
 
-// GetPollenTypes returns an array of all handled pollen types
-func (repo *PollenRepository) GetPollenTypes() ([]PollenType, error) {
-	return []PollenType{
-		PollenTypeGrass,
-		PollenTypeBirch,
-	}, nil
+// upsertPollenSampleQuery inserts or updates PollenArchive rows, keeping the counts of existing rows that are
+// NULL in the arguments on backends that upsertKeepsOtherColumns, see upsertPollenSamples for the others.
+// Takes Date, PollenType, Location, PollenCount and PredictedPollenCount of each row as arguments.
+func (repo *PollenRepository) upsertPollenSampleQuery(rows int) string {
+	return repo.backend.rebind(repo.backend.upsertNonNullQuery("PollenArchive",
+		[]string{"Date", "PollenType", "Location"},
+		[]string{"PollenCount", "PredictedPollenCount"}, rows))
 }
 
-// TimestampToDate converts a timestamp to a date used in the repository
+// TimestampToDate converts a timestamp to a date used in the repository. Dates are stored as midnight UTC
+// of the calendar day of timestamp, in the time zone of timestamp. Use Location.Date to get the date
+// in the calendar of a location.
 func TimestampToDate(timestamp time.Time) time.Time {
 	return time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), 0, 0, 0, 0, time.UTC)
 }
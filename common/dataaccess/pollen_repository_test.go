@@ -0,0 +1,141 @@
+package dataaccess
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRepository opens a SQLite database in a temporary file, so several connections share it
+func newTestRepository(t *testing.T, wrap func(backend) backend) *PollenRepository {
+	t.Helper()
+	sqlite := &sqlBackend{driver: DriverSQLite}
+	db, err := sqlite.connect(&DbConnectionConfig{SQLConnectionString: "file:" + t.TempDir() + "/pollen.db?_busy_timeout=5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(4)
+	var repoBackend backend = sqlite
+	if wrap != nil {
+		repoBackend = wrap(sqlite)
+	}
+	repo := &PollenRepository{DB: db, backend: repoBackend}
+	repo.InitDb()
+	return repo
+}
+
+// updateThenInsertBackend is SQLite made to upsert like Ignite, by updating and then inserting
+type updateThenInsertBackend struct {
+	*sqlBackend
+}
+
+func (updateThenInsertBackend) upsertKeepsOtherColumns() bool {
+	return false
+}
+
+// testStores are the stores the tests of PollenStore run against, each with Copenhagen as location 0
+var testStores = []struct {
+	name string
+	open func(t *testing.T) PollenStore
+}{
+	{"SQLite", func(t *testing.T) PollenStore {
+		return newTestRepository(t, nil)
+	}},
+	{"UpdateThenInsert", func(t *testing.T) PollenStore {
+		return newTestRepository(t, func(sqlite backend) backend {
+			return updateThenInsertBackend{sqlite.(*sqlBackend)}
+		})
+	}},
+	{"Memory", func(t *testing.T) PollenStore {
+		return NewMemoryRepository(copenhagen)
+	}},
+}
+
+// forEachStore runs test against a new store of each of testStores
+func forEachStore(t *testing.T, test func(t *testing.T, store PollenStore)) {
+	for _, testStore := range testStores {
+		testStore := testStore
+		t.Run(testStore.name, func(t *testing.T) {
+			test(t, testStore.open(t))
+		})
+	}
+}
+
+// upsertConcurrently upserts the pollen count and the predicted pollen count of the same dates from two
+// goroutines, and checks that both survive
+func upsertConcurrently(t *testing.T, store PollenStore) {
+	const days = 100
+	start := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	var wait sync.WaitGroup
+	wait.Add(2)
+	go func() {
+		defer wait.Done()
+		for i := 0; i < days; i++ {
+			count := i
+			sample := &PollenSample{Date: start.AddDate(0, 0, i), Location: Location{Location: 0}, PollenCount: &count}
+			if err := store.UpsertPollenCount(sample); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wait.Done()
+		for i := 0; i < days; i++ {
+			predicted := float32(i)
+			sample := &PollenSample{Date: start.AddDate(0, 0, i), Location: Location{Location: 0}, PredictedPollenCount: &predicted}
+			if err := store.UpsertPredictedPollenCount(sample); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wait.Wait()
+
+	samples, err := store.GetPollenFromRange(start, start.AddDate(0, 0, days-1), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != days {
+		t.Fatalf("got %d samples, want %d", len(samples), days)
+	}
+	for _, sample := range samples {
+		if sample.PollenCount == nil || sample.PredictedPollenCount == nil {
+			t.Errorf("%s: pollen count %v, predicted pollen count %v", sample.Date.Format("2006-01-02"),
+				sample.PollenCount, sample.PredictedPollenCount)
+		}
+	}
+}
+
+func TestConcurrentUpserts(t *testing.T) {
+	forEachStore(t, upsertConcurrently)
+}
+
+// TestMemoryRepositoryStartsLikeMigratedDatabase checks that a new MemoryRepository holds the pollen types and
+// feed stations created by the migrations
+func TestMemoryRepositoryStartsLikeMigratedDatabase(t *testing.T) {
+	stores := []PollenStore{newTestRepository(t, nil), NewMemoryRepository()}
+	var pollenTypes [][]*PollenTypeDefinition
+	var stations [][]*FeedStation
+	for _, store := range stores {
+		storeTypes, err := store.GetPollenTypes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		storeStations, err := store.GetFeedStations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pollenTypes, stations = append(pollenTypes, storeTypes), append(stations, storeStations)
+	}
+	if len(stations[0]) == 0 {
+		t.Error("the migrations created no feed stations")
+	}
+	if !reflect.DeepEqual(pollenTypes[0], pollenTypes[1]) {
+		t.Errorf("pollen types %+v in the database, %+v in memory", pollenTypes[0], pollenTypes[1])
+	}
+	if !reflect.DeepEqual(stations[0], stations[1]) {
+		t.Errorf("feed stations %+v in the database, %+v in memory", stations[0], stations[1])
+	}
+}
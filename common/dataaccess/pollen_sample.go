@@ -5,11 +5,12 @@ import (
 	"time"
 )
 
-// PollenSample holds a pollencount for a given date
+// PollenSample holds a pollencount for a given date. PollenCount and PredictedPollenCount are nil
+// when no value is known, which is different from a count of 0.
 type PollenSample struct {
 	PollenType           PollenType
-	PollenCount          int
-	PredictedPollenCount float32
+	PollenCount          *int
+	PredictedPollenCount *float32
 	Date                 time.Time
 	Location             Location
 }
@@ -21,11 +22,27 @@ type pollenSampleSQL struct {
 	PredictedPollenCount sql.NullFloat64
 	Date                 time.Time
 	Location             Location
+	TimeZone             sql.NullString
+	Latitude             sql.NullFloat64
+	Longitude            sql.NullFloat64
+	Elevation            sql.NullFloat64
+}
+
+// PollenForecast is a single prediction of the pollen count. Every forecast run is kept, so the
+// forecast published on a given day can be found later.
+type PollenForecast struct {
+	TargetDate           time.Time
+	PollenType           PollenType
+	Location             int
+	IssuedAt             time.Time
+	LeadDays             int
+	PredictedPollenCount float32
 }
 
-// PollenType denotes a type of pollen
+// PollenType denotes a type of pollen. The known types are in the PollenTypes table.
 type PollenType int
 
+// Pollen types used as defaults for backwards compatibility
 const (
 	// PollenTypeGrass grass
 	PollenTypeGrass PollenType = 0
@@ -33,15 +50,60 @@ const (
 	PollenTypeBirch PollenType = 1
 )
 
-func (pollenType PollenType) String() string {
-	switch pollenType {
-	case PollenTypeGrass:
-		return "Grass"
-	case PollenTypeBirch:
-		return "Birch"
-	default:
-		return ""
-	}
+// PollenTypeDefinition describes a pollen type, as stored in the PollenTypes table, and how it is
+// identified by the feeds the collector reads from
+type PollenTypeDefinition struct {
+	PollenType PollenType
+	// Code is a short lowercase name, e.g. "grass"
+	Code      string
+	NameEn    string
+	NameDa    string
+	LatinName string
+	// PredictionName is the name used by the prediction service, empty if it isn't predicted
+	PredictionName string
+	// FeedTypeID is the type_id used by the astma-allergi.dk feed, 0 if it isn't in the feed.
+	// It is the default for new FeedStations.
+	FeedTypeID int
+	// Thresholds decide the level of a count of the pollen type
+	Thresholds Thresholds
+}
+
+// FeedStation maps a location and pollen type to the station_id and type_id of the astma-allergi.dk feed
+type FeedStation struct {
+	Location   int
+	PollenType PollenType
+	StationID  int
+	TypeID     int
+}
+
+// defaultPollenTypes are the pollen types created by the migrations, and held by a new MemoryRepository
+var defaultPollenTypes = []PollenTypeDefinition{
+	{
+		PollenType:     PollenTypeGrass,
+		Code:           "grass",
+		NameEn:         "Grass",
+		NameDa:         "Græs",
+		LatinName:      "Poaceae",
+		PredictionName: "grass",
+		FeedTypeID:     28,
+		Thresholds:     Thresholds{Moderate: 10, High: 50, VeryHigh: 150},
+	},
+	{
+		PollenType:     PollenTypeBirch,
+		Code:           "birch",
+		NameEn:         "Birch",
+		NameDa:         "Birk",
+		LatinName:      "Betula",
+		PredictionName: "birch",
+		FeedTypeID:     7,
+		Thresholds:     Thresholds{Moderate: 30, High: 100, VeryHigh: 300},
+	},
+}
+
+// defaultFeedStations are the feed stations created by the migrations, and held by a new MemoryRepository
+var defaultFeedStations = []FeedStation{
+	{Location: 0, PollenType: PollenTypeGrass, StationID: 48, TypeID: 28},
+	{Location: 0, PollenType: PollenTypeBirch, StationID: 48, TypeID: 7},
 }
 
 // Location is a location where pollen is measured and predicted
@@ -49,4 +111,11 @@ type Location struct {
 	Location int
 	City     string
 	Country  string
+	// TimeZone is the IANA time zone of the location, e.g. "Europe/Copenhagen". Dates of the
+	// location follow its local calendar.
+	TimeZone string
+	// Latitude and Longitude are in degrees and Elevation in meters above sea level. They are nil when unknown.
+	Latitude  *float64
+	Longitude *float64
+	Elevation *float64
 }
@@ -0,0 +1,67 @@
+package dataaccess
+
+import "time"
+
+// PollenStore is the storage used by the API and the collector. It is implemented by
+// PollenRepository for Apache Ignite, SQLite and PostgreSQL, and by MemoryRepository for running without a database.
+type PollenStore interface {
+	// GetLocation fetch a location with an id
+	GetLocation(location int) (*Location, error)
+	// SearchLocation finds locations by country and/or city, best match first, and returns a page of them
+	SearchLocation(query *LocationQuery) (*LocationSearchResult, error)
+	// GetAllLocations fetch all locations
+	GetAllLocations() ([]*Location, error)
+	// CreateLocation inserts a new location, giving it the next free id
+	CreateLocation(location *Location) error
+	// UpdateLocation updates the country, city, time zone and coordinates of an existing location
+	UpdateLocation(location *Location) error
+	// DeleteLocation deletes a location, and its pollen data if cascade is set
+	DeleteLocation(location int, cascade bool) error
+	// GetPollenAvailability lists the first and last date with pollen data per location and pollen type
+	GetPollenAvailability() ([]*PollenAvailability, error)
+	// GetPollen fetch pollen data for a single date
+	GetPollen(date time.Time, pollenType PollenType, location int) (*PollenSample, error)
+	// GetPollenFromRange fetch pollen data for a range of dates
+	GetPollenFromRange(from time.Time, to time.Time, pollenType PollenType, location int) ([]*PollenSample, error)
+	// GetPollenFromRanges fetch pollen data for a range of dates of several pollen types and locations in a
+	// single query, sorted by location, pollen type and date
+	GetPollenFromRanges(query *PollenQuery) ([]*PollenSample, error)
+	// GetPollenAggregates sums up the pollen data of a query per day, week, month or season
+	GetPollenAggregates(query *PollenQuery, interval Interval) ([]*PollenAggregate, error)
+	// UpsertPredictedPollenCount insert/updates the predicted pollen count for a date
+	UpsertPredictedPollenCount(pollen *PollenSample) error
+	// UpsertPollenCount insert/updates the actual pollen count for a date
+	UpsertPollenCount(pollen *PollenSample) error
+	// UpsertPollenSample insert/updates the actual pollen count and predicted pollen count for a date.
+	// Unknown counts don't overwrite stored counts.
+	UpsertPollenSample(pollen *PollenSample) error
+	// BulkUpsertPollenSamples insert/updates many pollen samples, e.g. a full history. Unknown counts don't
+	// overwrite stored counts.
+	BulkUpsertPollenSamples(samples []*PollenSample) (*BulkResult, error)
+	// InsertPollenForecast stores a forecast. Forecasts are never overwritten, so every run is kept.
+	InsertPollenForecast(forecast *PollenForecast) error
+	// GetLatestPollenForecast fetch the most recently issued forecast for a date
+	GetLatestPollenForecast(date time.Time, pollenType PollenType, location int) (*PollenForecast, error)
+	// GetPollenForecastIssuedOn fetch the forecast for a date as it was published on the day issued, a date in
+	// the calendar of the location
+	GetPollenForecastIssuedOn(date time.Time, pollenType PollenType, location int, issued time.Time) (*PollenForecast, error)
+	// GetPollenForecasts fetch every forecast issued for a date, oldest first
+	GetPollenForecasts(date time.Time, pollenType PollenType, location int) ([]*PollenForecast, error)
+	// GetPollenTypes returns all handled pollen types
+	GetPollenTypes() ([]*PollenTypeDefinition, error)
+	// UpsertPollenType insert/updates a pollen type
+	UpsertPollenType(pollenType *PollenTypeDefinition) error
+	// GetFeedStations fetch all feed station mappings
+	GetFeedStations() ([]*FeedStation, error)
+	// GetFeedStation fetch the feed station for a location and pollen type
+	GetFeedStation(location int, pollenType PollenType) (*FeedStation, error)
+	// UpsertFeedStation insert/updates the feed station for a location and pollen type
+	UpsertFeedStation(station *FeedStation) error
+	// DeleteFeedStation deletes the feed station for a location and pollen type
+	DeleteFeedStation(location int, pollenType PollenType) error
+}
+
+var (
+	_ PollenStore = (*PollenRepository)(nil)
+	_ PollenStore = (*MemoryRepository)(nil)
+)
@@ -0,0 +1,150 @@
+package dataaccess
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultSearchLimit is the page size of a location search without a limit
+const DefaultSearchLimit = 20
+
+// MaxSearchLimit is the largest page size of a location search
+const MaxSearchLimit = 100
+
+// LocationQuery searches locations by country and/or city. Both are matched ignoring case and accents, and
+// match if they are equal to, start with or contain the search term. An empty term matches everything.
+type LocationQuery struct {
+	Country string
+	City    string
+	// Radius optionally limits the search to locations within a distance of a point
+	Radius *RadiusFilter
+	// Offset and Limit select a page of the ranked results. A Limit of 0 means DefaultSearchLimit.
+	Offset int
+	Limit  int
+}
+
+// LocationSearchResult is a page of locations found by a search, best match first
+type LocationSearchResult struct {
+	Locations []*Location
+	// Total is the number of locations found, across all pages
+	Total int
+}
+
+// Match ranks, from best to worst
+const (
+	matchNone = iota
+	matchSubstring
+	matchPrefix
+	matchExact
+)
+
+// accentFolds spells letters that don't decompose into a base letter and an accent
+var accentFolds = map[rune]string{
+	'æ': "ae", 'ø': "o", 'å': "a", 'ß': "ss", 'œ': "oe", 'ð': "d", 'þ': "th", 'ł': "l", 'đ': "d", 'ı': "i",
+}
+
+// accentBases maps accented latin letters to their base letter
+var accentBases = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'ā': 'a', 'ą': 'a', 'ă': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'ď': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ę': 'e', 'ě': 'e', 'ė': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'į': 'i',
+	'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r',
+	'ś': 's', 'š': 's', 'ş': 's', 'ș': 's',
+	'ť': 't', 'ţ': 't', 'ț': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u', 'ų': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ź': 'z', 'ż': 'z', 'ž': 'z',
+}
+
+// foldSearchText lower cases text, removes accents and trims space, so e.g. "København" becomes "kobenhavn"
+func foldSearchText(text string) string {
+	var builder strings.Builder
+	for _, r := range strings.TrimSpace(text) {
+		r = unicode.ToLower(r)
+		if fold, ok := accentFolds[r]; ok {
+			builder.WriteString(fold)
+		} else if base, ok := accentBases[r]; ok {
+			builder.WriteRune(base)
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// matchRank ranks how well value matches the folded search term
+func matchRank(value string, term string) int {
+	value = foldSearchText(value)
+	switch {
+	case value == term:
+		return matchExact
+	case strings.HasPrefix(value, term):
+		return matchPrefix
+	case strings.Contains(value, term):
+		return matchSubstring
+	}
+	return matchNone
+}
+
+// searchLocations finds the locations matching query, ranks them and returns the page asked for.
+// Locations are few, so searching is done here rather than in the database, which also makes
+// accents match the same way on every backend.
+func searchLocations(locations []*Location, query *LocationQuery) *LocationSearchResult {
+	country := foldSearchText(query.Country)
+	city := foldSearchText(query.City)
+
+	type rankedLocation struct {
+		location *Location
+		rank     int
+	}
+	var matches []rankedLocation
+	for _, location := range locations {
+		rank := 0
+		if country != "" {
+			countryRank := matchRank(location.Country, country)
+			if countryRank == matchNone {
+				continue
+			}
+			rank += countryRank
+		}
+		if city != "" {
+			cityRank := matchRank(location.City, city)
+			if cityRank == matchNone {
+				continue
+			}
+			rank += cityRank
+		}
+		if !query.Radius.Within(location) {
+			continue
+		}
+		matches = append(matches, rankedLocation{location: location, rank: rank})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank > matches[j].rank
+		}
+		if matches[i].location.Country != matches[j].location.Country {
+			return matches[i].location.Country < matches[j].location.Country
+		}
+		if matches[i].location.City != matches[j].location.City {
+			return matches[i].location.City < matches[j].location.City
+		}
+		return matches[i].location.Location < matches[j].location.Location
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	result := &LocationSearchResult{Locations: []*Location{}, Total: len(matches)}
+	for i := query.Offset; i < len(matches) && i < query.Offset+limit; i++ {
+		result.Locations = append(result.Locations, matches[i].location)
+	}
+	return result
+}
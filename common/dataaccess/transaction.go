@@ -0,0 +1,28 @@
+package dataaccess
+
+import "database/sql"
+
+// executor is implemented by both sql.DB and sql.Tx
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// withTransaction runs work in a transaction, which is rolled back if work fails.
+// Backends without transactions run work directly against the database.
+func (repo *PollenRepository) withTransaction(work func(exec executor) error) error {
+	if !repo.backend.supportsTransactions() {
+		return work(repo.DB)
+	}
+
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := work(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
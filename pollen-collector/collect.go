@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"log"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/admin"
 	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
 )
 
@@ -35,19 +37,30 @@ func main() {
 	}
 	defer pollenRepo.Close()
 
+	// Administration commands, e.g. "migrate up", run instead of collecting
+	if flag.NArg() > 0 {
+		if err := admin.Run(pollenRepo, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	pollenRepo.InitDb()
 
 	if *fullHistory {
 		log.Println("Collecting full history")
-		historicalPollen, err := getHistoricalPollen()
+		historicalPollen, skipped, err := getHistoricalPollen()
 		if err != nil {
-			log.Println(err)
-			return
+			log.Fatal(err)
+		}
+		result, err := importHistory(pollenRepo, historicalPollen)
+		if err != nil {
+			log.Fatalf("Full history import failed: %v", err)
 		}
-		var count = len(historicalPollen)
-		log.Printf("Found %v historical pollenSamples", count)
-		for index := 0; index < count; index++ {
-			pollenRepo.UpsertPollenSample(historicalPollen[index])
+		// The rows that were written are kept, so a partial import only needs to be reported
+		if result.Failed > 0 || skipped > 0 {
+			log.Printf("Full history import incomplete: %v rows failed, %v rows could not be parsed and were skipped",
+				result.Failed, skipped)
 		}
 		return
 	}
@@ -57,64 +70,139 @@ func main() {
 	waitGroup.Add(1)
 	go func() {
 		defer waitGroup.Done()
-		tomorrowsPollen, err := getTomorrowsPollen()
+		pollenTypes, err := pollenRepo.GetPollenTypes()
 		if err != nil {
 			log.Println(err)
 			return
 		}
-
-		dateForInsert := dataaccess.TimestampToDate(time.Now())
-		dateForInsert = dateForInsert.AddDate(0, 0, 1)
-
-		for _, pollenPrediction := range *tomorrowsPollen {
-			data := &dataaccess.PollenSample{
-				Date:                 dateForInsert,
-				PollenType:           pollenPrediction.PollenType,
-				Location:             dataaccess.Location{Location: 0},
-				PredictedPollenCount: pollenPrediction.PredictedPollenCount,
-			}
-			pollenRepo.UpsertPredictedPollenCount(data)
+		tomorrowsPollen, err := getTomorrowsPollen(pollenTypes)
+		if err != nil {
+			log.Println(err)
+			return
 		}
+		storePredictions(pollenRepo, *tomorrowsPollen, config.PredictionLocation, dataaccess.SystemClock)
 	}()
 
 	waitGroup.Add(1)
 	go func() {
 		defer waitGroup.Done()
-		pollenTypes, err := pollenRepo.GetPollenTypes()
+		collectPollenCounts(pollenRepo, GetPollenData)
+	}()
+
+	waitGroup.Wait()
+}
+
+// importHistory stores historical pollen counts and predictions
+func importHistory(store dataaccess.PollenStore, historicalPollen []*dataaccess.PollenSample) (*dataaccess.BulkResult, error) {
+	log.Printf("Found %v historical pollenSamples", len(historicalPollen))
+	result, err := store.BulkUpsertPollenSamples(historicalPollen)
+	log.Printf("Imported history: %v inserted, %v updated, %v failed", result.Inserted, result.Updated, result.Failed)
+	return result, err
+}
+
+// storePredictions stores the predictions made now as the predictions for tomorrow, in the calendar
+// of the predicted location. Each prediction is also kept in the forecast history.
+func storePredictions(store dataaccess.PollenStore, predictions []*PollenPrediction, locationID int, clock dataaccess.Clock) {
+	location, err := store.GetLocation(locationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	// With an unknown time zone tomorrow would silently be in the calendar of UTC
+	if _, err := time.LoadLocation(location.TimeZone); err != nil {
+		log.Printf("Skipping the predictions for %v, %v: %v", location.City, location.Country, err)
+		return
+	}
+	now := clock.Now()
+	dateForInsert, ok := location.RelativeDate(clock, "tomorrow")
+	if !ok {
+		log.Printf("Skipping the predictions for %v, %v: no date for tomorrow", location.City, location.Country)
+		return
+	}
+
+	for _, pollenPrediction := range predictions {
+		err := store.InsertPollenForecast(&dataaccess.PollenForecast{
+			TargetDate:           dateForInsert,
+			PollenType:           pollenPrediction.PollenType,
+			Location:             location.Location,
+			IssuedAt:             now.UTC(),
+			LeadDays:             1,
+			PredictedPollenCount: pollenPrediction.PredictedPollenCount,
+		})
 		if err != nil {
-			log.Println(err)
-			return
+			log.Printf("Failed to keep the forecast of pollen type %v for %v: %v", pollenPrediction.PollenType, dateForInsert, err)
 		}
-		locations, err := pollenRepo.GetAllLocations()
-		if err != nil {
-			log.Println(err)
-			return
+		predictedPollenCount := pollenPrediction.PredictedPollenCount
+		data := &dataaccess.PollenSample{
+			Date:                 dateForInsert,
+			PollenType:           pollenPrediction.PollenType,
+			Location:             dataaccess.Location{Location: location.Location},
+			PredictedPollenCount: &predictedPollenCount,
+		}
+		if err := store.UpsertPredictedPollenCount(data); err != nil {
+			log.Printf("Failed to store the prediction of pollen type %v for %v: %v", pollenPrediction.PollenType, dateForInsert, err)
 		}
-		for _, pollenType := range pollenTypes {
-			for _, location := range locations {
-				pollenData, err := GetPollenData(pollenType, location)
-				if err != nil {
-					log.Println(err)
-					return
+	}
+}
+
+// pollenDataSource retrieves the measured pollen counts from a feed station
+type pollenDataSource func(station *dataaccess.FeedStation) ([]*HistoricalPollenCount, error)
+
+// collectPollenCounts updates the measured pollen counts of every pollen type and location from source.
+// Combinations without a feed station are skipped.
+func collectPollenCounts(store dataaccess.PollenStore, source pollenDataSource) {
+	pollenTypes, err := store.GetPollenTypes()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	locations, err := store.GetAllLocations()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, pollenType := range pollenTypes {
+		for _, location := range locations {
+			station, err := store.GetFeedStation(location.Location, pollenType.PollenType)
+			if err == sql.ErrNoRows {
+				log.Printf("Warning: no feed station for %v in %v, %v. Skipping it",
+					pollenType.Code, location.City, location.Country)
+				continue
+			}
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			pollenData, err := source(station)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			log.Printf("Found data for %v days", len(pollenData))
+
+			// Update the last 14 days of data
+			first := len(pollenData) - 15
+			if first < 0 {
+				first = 0
+			}
+			for i := first; i < len(pollenData); i++ {
+				pollenData := pollenData[i]
+				if pollenData.PollenCount == nil {
+					log.Printf("No measurement for %v", pollenData.Date)
+					continue
+				}
+				log.Printf("Updating %v", pollenData.Date)
+
+				data := &dataaccess.PollenSample{
+					Date:        dataaccess.TimestampToDate(pollenData.Date),
+					PollenType:  pollenType.PollenType,
+					Location:    dataaccess.Location{Location: location.Location},
+					PollenCount: pollenData.PollenCount,
 				}
-				log.Printf("Found data for %v days", len(pollenData))
-
-				// Update the last 14 days of data
-				for i := len(pollenData) - 15; i < len(pollenData); i++ {
-					pollenData := pollenData[i]
-					log.Printf("Updating %v", pollenData.Date)
-
-					data := &dataaccess.PollenSample{
-						Date:        dataaccess.TimestampToDate(pollenData.Date),
-						PollenType:  pollenType,
-						Location:    dataaccess.Location{Location: location.Location},
-						PollenCount: pollenData.PollenCount,
-					}
-					pollenRepo.UpsertPollenCount(data)
+				if err := store.UpsertPollenCount(data); err != nil {
+					log.Printf("Failed to store the pollen count of %v for %v: %v", pollenType.Code, pollenData.Date, err)
 				}
 			}
 		}
-	}()
-
-	waitGroup.Wait()
+	}
 }
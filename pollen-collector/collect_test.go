@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
+)
+
+var copenhagen = dataaccess.Location{Location: 0, Country: "Denmark", City: "Copenhagen", TimeZone: "Europe/Copenhagen"}
+
+func fixedClock(now time.Time) dataaccess.Clock {
+	return dataaccess.ClockFunc(func() time.Time { return now })
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// At 22:30 UTC on May 1 it is already May 2 in Copenhagen, so the predictions are for May 3
+func TestStorePredictions(t *testing.T) {
+	store := dataaccess.NewMemoryRepository(copenhagen)
+	now := time.Date(2020, time.May, 1, 22, 30, 0, 0, time.UTC)
+	predictions := []*PollenPrediction{
+		{PollenType: dataaccess.PollenTypeGrass, PredictedPollenCount: 12},
+		{PollenType: dataaccess.PollenTypeBirch, PredictedPollenCount: 34},
+	}
+	storePredictions(store, predictions, copenhagen.Location, fixedClock(now))
+
+	for _, prediction := range predictions {
+		sample, err := store.GetPollen(date(2020, time.May, 3), prediction.PollenType, copenhagen.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample.PredictedPollenCount == nil || *sample.PredictedPollenCount != prediction.PredictedPollenCount {
+			t.Errorf("pollen type %v: predicted pollen count %v, want %v", prediction.PollenType,
+				sample.PredictedPollenCount, prediction.PredictedPollenCount)
+		}
+		forecasts, err := store.GetPollenForecasts(date(2020, time.May, 3), prediction.PollenType, copenhagen.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forecasts) != 1 || !forecasts[0].IssuedAt.Equal(now) || forecasts[0].LeadDays != 1 {
+			t.Errorf("pollen type %v: forecasts %+v, want one issued at %v", prediction.PollenType, forecasts, now)
+		}
+	}
+}
+
+func TestStorePredictionsSkipsUnknownTimeZones(t *testing.T) {
+	location := dataaccess.Location{Location: 3, Country: "Nowhere", City: "Nowhere", TimeZone: "Nowhere/Nowhere"}
+	store := dataaccess.NewMemoryRepository(copenhagen, location)
+	now := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	storePredictions(store, []*PollenPrediction{{PollenType: dataaccess.PollenTypeGrass, PredictedPollenCount: 12}},
+		location.Location, fixedClock(now))
+
+	samples, err := store.GetPollenFromRanges(&dataaccess.PollenQuery{From: time.Time{}, To: date(2100, time.January, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 0 {
+		t.Errorf("stored %d samples, want none", len(samples))
+	}
+}
+
+// TestCollectPollenCounts collects the last 15 days of the feed of every pollen type at a location with feed
+// stations, and skips the location without
+func TestCollectPollenCounts(t *testing.T) {
+	aarhus := dataaccess.Location{Location: 1, Country: "Denmark", City: "Aarhus", TimeZone: "Europe/Copenhagen"}
+	store := dataaccess.NewMemoryRepository(copenhagen, aarhus)
+	start := date(2020, time.May, 1)
+	var requested []dataaccess.FeedStation
+	source := func(station *dataaccess.FeedStation) ([]*HistoricalPollenCount, error) {
+		requested = append(requested, *station)
+		var counts []*HistoricalPollenCount
+		for i := 0; i < 20; i++ {
+			count := station.TypeID*100 + i
+			day := &HistoricalPollenCount{Date: start.AddDate(0, 0, i), PollenCount: &count}
+			if i == 17 {
+				day.PollenCount = nil
+			}
+			counts = append(counts, day)
+		}
+		return counts, nil
+	}
+	collectPollenCounts(store, source)
+
+	if len(requested) != 2 {
+		t.Errorf("requested %+v, want the feed stations of grass and birch in Copenhagen", requested)
+	}
+	for _, station := range requested {
+		samples, err := store.GetPollenFromRange(start, start.AddDate(0, 0, 19), station.PollenType, station.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 14 {
+			t.Fatalf("pollen type %v: got %d samples, want the 14 measured of the last 15 days", station.PollenType, len(samples))
+		}
+		if first := samples[0]; !first.Date.Equal(start.AddDate(0, 0, 5)) || *first.PollenCount != station.TypeID*100+5 {
+			t.Errorf("pollen type %v: first sample %v with %v, want May 6 with %v", station.PollenType,
+				first.Date, *first.PollenCount, station.TypeID*100+5)
+		}
+	}
+	samples, err := store.GetPollenFromRanges(&dataaccess.PollenQuery{From: start, To: start.AddDate(0, 0, 19), Locations: []int{aarhus.Location}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 0 {
+		t.Errorf("stored %d samples of Aarhus, which has no feed stations", len(samples))
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	store := dataaccess.NewMemoryRepository(copenhagen)
+	count := 12
+	store.UpsertPollenCount(&dataaccess.PollenSample{Date: date(2020, time.May, 1), Location: copenhagen, PollenCount: &count})
+
+	var history []*dataaccess.PollenSample
+	for i := 0; i < 3; i++ {
+		predicted := float32(i)
+		history = append(history, &dataaccess.PollenSample{Date: date(2020, time.May, 1+i), Location: copenhagen,
+			PredictedPollenCount: &predicted})
+	}
+	result, err := importHistory(store, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 2 || result.Updated != 1 || result.Failed != 0 {
+		t.Errorf("inserted %d, updated %d and failed %d, want 2, 1 and 0", result.Inserted, result.Updated, result.Failed)
+	}
+	sample, err := store.GetPollen(date(2020, time.May, 1), dataaccess.PollenTypeGrass, copenhagen.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.PollenCount == nil || *sample.PollenCount != count || sample.PredictedPollenCount == nil {
+		t.Errorf("pollen count %v and predicted pollen count %v, want %v and 0", sample.PollenCount,
+			sample.PredictedPollenCount, count)
+	}
+}
@@ -12,6 +12,8 @@ type CollectorConfig struct {
 	PredictionAPIKey      string
 	HistoricalAPIEndpoint string
 	HistoricalAPIKey      string
+	// PredictionLocation is the id of the location the prediction services predict for, 0 by default
+	PredictionLocation int
 }
 
 func getConfig() *CollectorConfig {
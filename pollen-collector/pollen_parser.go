@@ -15,10 +15,11 @@ import (
 	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
 )
 
-// HistoricalPollenCount holds pollen data scraped from astma-allergi.dk
+// HistoricalPollenCount holds pollen data scraped from astma-allergi.dk.
+// PollenCount is nil for days without a measurement.
 type HistoricalPollenCount struct {
 	Date        time.Time
-	PollenCount int
+	PollenCount *int
 }
 
 type highchartSeries struct {
@@ -27,30 +28,9 @@ type highchartSeries struct {
 	Data    [][]interface{} `json:"data"`
 }
 
-// GetPollenData retrieves historical pollen data from astma-allergi.dk and parses them out to an array
-func GetPollenData(pollenType dataaccess.PollenType, location *dataaccess.Location) ([]*HistoricalPollenCount, error) {
-	var stationID, typeID int
-	switch pollenType {
-	case dataaccess.PollenTypeGrass:
-		typeID = 28
-		break
-	case dataaccess.PollenTypeBirch:
-		typeID = 7
-		break
-	default:
-		panic("Unknown pollen type")
-	}
-
-	// TODO: this mapping should really not be maintained in code once we handle more than one location
-	switch location.Location {
-	case 0:
-		stationID = 48
-		break
-	default:
-		panic("Unknown location")
-	}
-
-	return getPollenData(stationID, typeID)
+// GetPollenData retrieves historical pollen data for a feed station from astma-allergi.dk and parses them out to an array
+func GetPollenData(station *dataaccess.FeedStation) ([]*HistoricalPollenCount, error) {
+	return getPollenData(station.StationID, station.TypeID)
 }
 
 // getPollenData retrieves historical pollen data from astma-allergi.dk and parses them out to an array
@@ -120,7 +100,11 @@ func parsePollenDataBody(body string) ([]*HistoricalPollenCount, error) {
 			continue
 		}
 		for _, pollenDay := range pollenYear.Data {
-			pollenCount := int(pollenDay[1].(float64))
+			var pollenCount *int
+			if value, ok := pollenDay[1].(float64); ok {
+				count := int(value)
+				pollenCount = &count
+			}
 
 			matches := dateRegex.FindStringSubmatch(pollenDay[0].(string))
 			month, err := strconv.Atoi(matches[1])
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Tomorrows-pollen-today/yesterdays-pollen-today/common/dataaccess"
@@ -32,11 +33,22 @@ type PollenPrediction struct {
 	PredictedPollenCount float32
 }
 
-func parsePredictionValues(values [][]string) (*[]*PollenPrediction, error) {
+// predictionNames maps the names used by the prediction service to pollen types
+func predictionNames(pollenTypes []*dataaccess.PollenTypeDefinition) map[string]dataaccess.PollenType {
+	names := make(map[string]dataaccess.PollenType)
+	for _, pollenType := range pollenTypes {
+		if pollenType.PredictionName != "" {
+			names[pollenType.PredictionName] = pollenType.PollenType
+		}
+	}
+	return names
+}
+
+func parsePredictionValues(values [][]string, names map[string]dataaccess.PollenType) (*[]*PollenPrediction, error) {
 	result := make([]*PollenPrediction, len(values))
 	for i, value := range values {
 		var err error
-		result[i], err = parsePredictionValue(value)
+		result[i], err = parsePredictionValue(value, names)
 		if err != nil {
 			return &result, err
 		}
@@ -44,16 +56,13 @@ func parsePredictionValues(values [][]string) (*[]*PollenPrediction, error) {
 	return &result, nil
 }
 
-func parsePredictionValue(value []string) (*PollenPrediction, error) {
+func parsePredictionValue(value []string, names map[string]dataaccess.PollenType) (*PollenPrediction, error) {
 	prediction := &PollenPrediction{}
-	switch value[0] {
-	case "birch":
-		prediction.PollenType = dataaccess.PollenTypeBirch
-	case "grass":
-		prediction.PollenType = dataaccess.PollenTypeGrass
-	default:
+	pollenType, ok := names[value[0]]
+	if !ok {
 		return nil, fmt.Errorf("Unknown pollen type: %s", value[0])
 	}
+	prediction.PollenType = pollenType
 	parsedFloat, err := strconv.ParseFloat(value[1], 32)
 	if err != nil {
 		return nil, err
@@ -62,7 +71,7 @@ func parsePredictionValue(value []string) (*PollenPrediction, error) {
 	return prediction, nil
 }
 
-func getTomorrowsPollen() (*[]*PollenPrediction, error) {
+func getTomorrowsPollen(pollenTypes []*dataaccess.PollenTypeDefinition) (*[]*PollenPrediction, error) {
 	client := &http.Client{}
 	postBody, err := json.Marshal(map[string]interface{}{"GlobalParameters": map[string]string{
 		"Output_name": "",
@@ -95,7 +104,7 @@ func getTomorrowsPollen() (*[]*PollenPrediction, error) {
 		return nil, err
 	}
 
-	return parsePredictionValues(tomorrowsPollen.Results.PredictedPollenCount.Value.Values)
+	return parsePredictionValues(tomorrowsPollen.Results.PredictedPollenCount.Value.Values, predictionNames(pollenTypes))
 }
 
 type azureHistoricalPollenResponse struct {
@@ -111,7 +120,9 @@ type azureHistoricalPollenResponse struct {
 	} `json:"Results"`
 }
 
-func getHistoricalPollen() ([]*dataaccess.PollenSample, error) {
+// getHistoricalPollen retrieves the full history from the historical predictions service.
+// It also returns the number of rows that could not be parsed and were skipped.
+func getHistoricalPollen() ([]*dataaccess.PollenSample, int, error) {
 	client := &http.Client{}
 	postBody, err := json.Marshal(map[string]interface{}{"GlobalParameters": map[string]string{}})
 	if err != nil {
@@ -129,7 +140,7 @@ func getHistoricalPollen() ([]*dataaccess.PollenSample, error) {
 	historicalPollenResponse, err := client.Do(request)
 	if err != nil {
 		log.Fatal(err, historicalPollenResponse)
-		return nil, err
+		return nil, 0, err
 	}
 	defer historicalPollenResponse.Body.Close()
 	data, _ := ioutil.ReadAll(historicalPollenResponse.Body)
@@ -138,35 +149,73 @@ func getHistoricalPollen() ([]*dataaccess.PollenSample, error) {
 	err = json.Unmarshal(data, &historicalPollen)
 	if err != nil {
 		log.Println(err, historicalPollenResponse, string(data))
-		return nil, err
+		return nil, 0, err
 	}
 	var result []*dataaccess.PollenSample
+	skipped := 0
 	var count = len(historicalPollen.Results.HistoricalPollenCount.Value.Values)
 	for index := 0; index < count; index++ {
 		var currentResult = historicalPollen.Results.HistoricalPollenCount.Value.Values[index]
 		date, err := time.Parse("1/2/2006 15:04:05 AM", currentResult[0])
 		if err != nil {
 			log.Println(err, currentResult)
+			skipped++
 			continue
 		}
-		historicalPollenValue, err := strconv.Atoi(currentResult[1])
+		historicalPollenValue, err := parseOptionalInt(currentResult[1])
 		if err != nil {
 			log.Println(err, currentResult)
+			skipped++
 			continue
 		}
-		historicalPredictedPollenValue, err := strconv.ParseFloat(currentResult[2], 32)
+		predictedPollenCount, err := parseOptionalFloat(currentResult[2])
 		if err != nil {
 			log.Println(err, currentResult)
+			skipped++
 			continue
 		}
-		var predictedPollenCount = float32(historicalPredictedPollenValue)
 		var pollenSample = &dataaccess.PollenSample{
 			Date:                 dataaccess.TimestampToDate(date),
+			Location:             dataaccess.Location{Location: config.PredictionLocation},
 			PollenCount:          historicalPollenValue,
 			PredictedPollenCount: predictedPollenCount,
 		}
 		result = append(result, pollenSample)
 	}
-	return result, nil
+	return result, skipped, nil
+}
 
+// parseOptionalInt parses an integer from the prediction service. Missing values give nil.
+func parseOptionalInt(value string) (*int, error) {
+	if isMissingValue(value) {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// parseOptionalFloat parses a float from the prediction service. Missing values give nil.
+func parseOptionalFloat(value string) (*float32, error) {
+	if isMissingValue(value) {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return nil, err
+	}
+	result := float32(parsed)
+	return &result, nil
+}
+
+// isMissingValue tells if a value from Azure ML studio represents a missing value
+func isMissingValue(value string) bool {
+	switch strings.TrimSpace(value) {
+	case "", "NaN", "null", "NA":
+		return true
+	default:
+		return false
+	}
 }